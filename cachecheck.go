@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	netv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// cacheSyncLogInterval is how often waiting for the initial cache sync is
+// logged.
+const cacheSyncLogInterval = 10 * time.Second
+
+// logCacheSync logs progress while waiting for the initial cache sync, and
+// the likely causes if it times out. The controller itself fails once the
+// timeout passes, stopping the manager.
+func logCacheSync(ctx context.Context, log logr.Logger, c cache.Cache, timeout time.Duration) {
+	start := time.Now()
+	synced := make(chan bool, 1)
+	go func() {
+		syncCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		// Waiting for the cache to sync only covers the informers that
+		// exist, so also wait for the ingress informer, which blocks once the
+		// cache has started.
+		if !c.WaitForCacheSync(syncCtx) {
+			synced <- false
+			return
+		}
+		_, err := c.GetInformer(syncCtx, &netv1.Ingress{})
+		synced <- err == nil
+	}()
+
+	ticker := time.NewTicker(cacheSyncLogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case ok := <-synced:
+			switch {
+			case ok:
+				log.Info("Ingress cache synced", "duration", time.Since(start).Round(time.Millisecond))
+			case ctx.Err() == nil:
+				log.Error(nil, "Timed out waiting for the ingress cache to sync, check that the API server is reachable and that ingresses can be listed and watched", "timeout", timeout)
+			}
+			return
+		case <-ticker.C:
+			log.Info("Waiting for the ingress cache to sync", "elapsed", time.Since(start).Round(time.Second))
+		}
+	}
+}
+
+// cacheChecker detects an informer that has stopped receiving events by
+// periodically comparing the cached ingresses with those listed directly from
+// the API server. Comparing against the API server rather than waiting for
+// resource versions to advance means quiet clusters aren't mistaken for
+// stale ones.
+type cacheChecker struct {
+	log       logr.Logger
+	cached    client.Reader
+	live      client.Reader
+	threshold time.Duration
+	// divergedSince holds the Unix nanoseconds at which the cache was first
+	// seen to differ from the API server, or zero if it last matched.
+	divergedSince atomic.Int64
+}
+
+func newCacheChecker(log logr.Logger, cached, live client.Reader, threshold time.Duration) *cacheChecker {
+	return &cacheChecker{
+		log:       log.WithName("cache-check"),
+		cached:    cached,
+		live:      live,
+		threshold: threshold,
+	}
+}
+
+// Start compares the cache until the context is cancelled. It implements
+// manager.Runnable.
+func (c *cacheChecker) Start(ctx context.Context) error {
+	ticker := time.NewTicker(c.threshold / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			c.compare(ctx)
+		}
+	}
+}
+
+func (c *cacheChecker) compare(ctx context.Context) {
+	cached, err := c.versions(ctx, c.cached)
+	if err != nil {
+		c.log.Error(err, "Failed to list cached ingresses")
+		return
+	}
+	live, err := c.versions(ctx, c.live)
+	if err != nil {
+		// The API server being unavailable says nothing about the cache.
+		c.log.Error(err, "Failed to list ingresses from the API server")
+		return
+	}
+
+	matches := len(cached) == len(live)
+	for uid, version := range live {
+		matches = matches && cached[uid] == version
+	}
+	switch {
+	case matches:
+		c.divergedSince.Store(0)
+	case c.divergedSince.Load() == 0:
+		// Changes in flight differ briefly, so only the threshold fails the
+		// check.
+		c.log.V(1).Info("Cached ingresses differ from the API server", "cached", len(cached), "live", len(live))
+		c.divergedSince.Store(time.Now().UnixNano())
+	}
+}
+
+func (c *cacheChecker) versions(ctx context.Context, reader client.Reader) (map[types.UID]string, error) {
+	is := &netv1.IngressList{}
+	if err := reader.List(ctx, is); err != nil {
+		return nil, err
+	}
+	versions := make(map[types.UID]string, len(is.Items))
+	for _, item := range is.Items {
+		versions[item.UID] = item.ResourceVersion
+	}
+	return versions, nil
+}
+
+// Check fails if the cache has differed from the API server for longer than
+// the threshold. It implements healthz.Checker.
+func (c *cacheChecker) Check(*http.Request) error {
+	since := c.divergedSince.Load()
+	if since == 0 {
+		return nil
+	}
+	if diverged := time.Since(time.Unix(0, since)); diverged > c.threshold {
+		return fmt.Errorf("cached ingresses have differed from the API server for %s", diverged.Round(time.Second))
+	}
+	return nil
+}
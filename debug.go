@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/go-logr/logr"
+	netv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// liveReconcileName is the name of the reconcile requests sent by the debug
+// endpoint, which list ingresses from the API server rather than the cache.
+const liveReconcileName = "debug-reconcile"
+
+// debugReconcileHandler serves POST /debug/reconcile, which re-renders the
+// page from ingresses listed directly from the API server and reports the
+// number of links of the given ingress before and after, so that annotation
+// changes can be checked without waiting for the informer.
+func debugReconcileHandler(log logr.Logger, pagePtr *atomic.Pointer[renderSnapshot], reader client.Reader, reconcile func(context.Context) error) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		key := types.NamespacedName{Namespace: query.Get("namespace"), Name: query.Get("name")}
+		if key.Namespace == "" || key.Name == "" {
+			jsonError(rw, "namespace and name are required", http.StatusBadRequest)
+			return
+		}
+
+		item := &netv1.Ingress{}
+		if err := reader.Get(req.Context(), key, item); apierrors.IsNotFound(err) {
+			jsonError(rw, "ingress not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Error(err, "Failed to get ingress for debug reconcile", "namespace", key.Namespace, "name", key.Name)
+			jsonError(rw, "failed to get ingress", http.StatusInternalServerError)
+			return
+		}
+
+		before := ingressLinkCount(item, pagePtr.Load())
+		if err := reconcile(req.Context()); err != nil {
+			log.Error(err, "Debug reconcile failed", "namespace", key.Namespace, "name", key.Name)
+			jsonError(rw, "reconcile failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		after := ingressLinkCount(item, pagePtr.Load())
+		log.Info("Debug reconcile completed", "namespace", key.Namespace, "name", key.Name, "before", before, "after", after)
+
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(rw).Encode(struct {
+			Namespace string `json:"namespace"`
+			Name      string `json:"name"`
+			Before    int    `json:"before"`
+			After     int    `json:"after"`
+		}{key.Namespace, key.Name, before, after})
+	}
+}
+
+// ingressLinkCount counts the host and path links on the page for the hosts
+// and paths of an ingress. Hosts declared by several ingresses count for each.
+func ingressLinkCount(item *netv1.Ingress, snapshot *renderSnapshot) int {
+	if snapshot == nil {
+		return 0
+	}
+	count := 0
+	counted := map[string]bool{}
+	for _, rule := range item.Spec.Rules {
+		hv := snapshot.HostsByName[rule.Host]
+		if hv == nil {
+			continue
+		}
+		if !counted[rule.Host] {
+			counted[rule.Host] = true
+			count++
+		}
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			if path.Path != "/" && hv.Paths[path.Path] != nil && !counted[rule.Host+path.Path] {
+				counted[rule.Host+path.Path] = true
+				count++
+			}
+		}
+	}
+	return count
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// feedEntries bounds the number of link changes kept for the feed.
+const feedEntries = 50
+
+// linkChange records the hosts added and removed by a render.
+type linkChange struct {
+	Time    time.Time
+	Added   []*hostValues
+	Removed []*hostValues
+}
+
+// linkFeed keeps the recent link changes for the Atom feed. Changes are
+// recorded by the reconciler and read by request handlers.
+type linkFeed struct {
+	instance string
+
+	mu      sync.Mutex
+	changes []linkChange
+}
+
+func newLinkFeed(instance string) *linkFeed {
+	return &linkFeed{instance: instance}
+}
+
+// Record adds the hosts added and removed between two renders, if any,
+// dropping the oldest change once the feed is full.
+func (f *linkFeed) Record(at time.Time, oldHosts, newHosts []*hostValues) {
+	change := linkChange{Time: at}
+	hostNames := func(hosts []*hostValues) []string {
+		names := make([]string, 0, len(hosts))
+		for _, hv := range hosts {
+			names = append(names, hv.Host)
+		}
+		return names
+	}
+	oldNames, newNames := hostNames(oldHosts), hostNames(newHosts)
+	for _, hv := range newHosts {
+		if !slices.Contains(oldNames, hv.Host) {
+			change.Added = append(change.Added, hv)
+		}
+	}
+	for _, hv := range oldHosts {
+		if !slices.Contains(newNames, hv.Host) {
+			change.Removed = append(change.Removed, hv)
+		}
+	}
+	if len(change.Added) == 0 && len(change.Removed) == 0 {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.changes = append(f.changes, change)
+	if len(f.changes) > feedEntries {
+		f.changes = slices.Delete(f.changes, 0, len(f.changes)-feedEntries)
+	}
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID      string `xml:"id"`
+	Title   string `xml:"title"`
+	Updated string `xml:"updated"`
+	Content string `xml:"content"`
+}
+
+// WriteAtom writes the recorded changes as an Atom feed, newest first,
+// including only the hosts visible to a user in the given groups.
+func (f *linkFeed) WriteAtom(w io.Writer, groups []string) error {
+	f.mu.Lock()
+	changes := slices.Clone(f.changes)
+	f.mu.Unlock()
+
+	feed := atomFeed{
+		ID:     "urn:ingress-links-controller:" + f.instance,
+		Title:  "Link changes on " + f.instance,
+		Author: atomAuthor{Name: f.instance},
+		// Atom requires an updated time even for an empty feed.
+		Updated: time.Unix(0, lastRender.Load()).UTC().Format(time.RFC3339),
+	}
+	for _, change := range slices.Backward(changes) {
+		added, removed := visibleHosts(change.Added, groups), visibleHosts(change.Removed, groups)
+		if len(added) == 0 && len(removed) == 0 {
+			continue
+		}
+		if len(feed.Entries) == 0 {
+			feed.Updated = change.Time.UTC().Format(time.RFC3339)
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			// Generations restart with the controller, so the time is used
+			// to identify entries instead.
+			ID:      fmt.Sprintf("%s:%d", feed.ID, change.Time.UnixNano()),
+			Title:   fmt.Sprintf("%d links added, %d removed", len(added), len(removed)),
+			Updated: change.Time.UTC().Format(time.RFC3339),
+			Content: changeSummary(added, removed),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	return enc.Encode(feed)
+}
+
+func changeSummary(added, removed []*hostValues) string {
+	var lines []string
+	for _, hv := range added {
+		lines = append(lines, "Added "+hv.URL)
+	}
+	for _, hv := range removed {
+		lines = append(lines, "Removed "+hv.URL)
+	}
+	return strings.Join(lines, "\n")
+}
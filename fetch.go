@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+const (
+	// maxDescriptionBytes bounds how much of a description response is read.
+	maxDescriptionBytes = 4 << 10
+	// maxFaviconBytes bounds the size of favicons inlined into the page.
+	maxFaviconBytes = 32 << 10
+)
+
+// cachedFetcher fetches values from URLs in the background, caching both
+// successes and failures for the TTL so that each URL is fetched at most once
+// per TTL. Reconciles never wait for a fetch: they render with the cached
+// value, or without one, and changed sends an event to render again once a
+// fetch gives a new value.
+type cachedFetcher struct {
+	log    logr.Logger
+	client *http.Client
+	ttl    time.Duration
+	// accept is the Accept header sent with requests.
+	accept string
+	// allowed rejects URLs that must not be fetched, if set.
+	allowed func(u *url.URL) error
+	// read converts a successful response into the cached value.
+	read    func(resp *http.Response) (string, error)
+	changed chan event.GenericEvent
+
+	// mu guards the cache against the background fetches.
+	mu       sync.Mutex
+	cache    map[string]fetchedValue
+	fetching map[string]bool
+}
+
+type fetchedValue struct {
+	value     string
+	fetchedAt time.Time
+}
+
+// newDescriptionFetcher fetches link descriptions from the URLs given in
+// description-url annotations. URLs are only fetched, and redirects only
+// followed, if they are http or https URLs of hosts matching the glob patterns.
+func newDescriptionFetcher(log logr.Logger, client *http.Client, ttl time.Duration, hosts []string) *cachedFetcher {
+	allowed := func(u *url.URL) error {
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("unsupported scheme %q", u.Scheme)
+		}
+		if !slices.ContainsFunc(hosts, func(pattern string) bool {
+			matched, _ := path.Match(pattern, u.Hostname())
+			return matched
+		}) {
+			return fmt.Errorf("host %s does not match --description-host", u.Hostname())
+		}
+		return nil
+	}
+	restricted := *client
+	restricted.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		// The default limit of redirects.
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return allowed(req.URL)
+	}
+	f := newCachedFetcher(log.WithName("descriptions"), &restricted, ttl, "text/plain", readDescription)
+	f.allowed = allowed
+	return f
+}
+
+// newFaviconFetcher fetches favicons as data URIs, so that viewing the page
+// does not make requests to every host.
+func newFaviconFetcher(log logr.Logger, client *http.Client, ttl time.Duration) *cachedFetcher {
+	return newCachedFetcher(log.WithName("favicons"), client, ttl, "image/*", readFavicon)
+}
+
+func newCachedFetcher(log logr.Logger, client *http.Client, ttl time.Duration, accept string, read func(resp *http.Response) (string, error)) *cachedFetcher {
+	return &cachedFetcher{
+		log:      log,
+		client:   client,
+		ttl:      ttl,
+		accept:   accept,
+		read:     read,
+		changed:  make(chan event.GenericEvent, 1),
+		cache:    map[string]fetchedValue{},
+		fetching: map[string]bool{},
+	}
+}
+
+// Get returns the cached value at the URL, which is empty until it has been
+// fetched. If the value is not cached or has expired, it is fetched in the
+// background, and the expired value is returned in the meantime. The fetch is
+// detached from the context's cancellation, so that it outlives the
+// reconcile, and is bounded by the client's timeout instead.
+func (f *cachedFetcher) Get(ctx context.Context, url string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cached, ok := f.cache[url]
+	if (!ok || time.Since(cached.fetchedAt) >= f.ttl) && !f.fetching[url] {
+		f.fetching[url] = true
+		go f.refresh(context.WithoutCancel(ctx), url)
+	}
+	return cached.value
+}
+
+// Changed is sent an event whenever a fetch changes a value.
+func (f *cachedFetcher) Changed() <-chan event.GenericEvent {
+	return f.changed
+}
+
+// refresh fetches and caches the value at the URL. Failed fetches are logged
+// and cache an empty value.
+func (f *cachedFetcher) refresh(ctx context.Context, url string) {
+	value, err := f.fetch(ctx, url)
+	if err != nil {
+		f.log.Error(err, "Failed to fetch", "url", url)
+	}
+
+	f.mu.Lock()
+	previous := f.cache[url]
+	f.cache[url] = fetchedValue{value: value, fetchedAt: time.Now()}
+	delete(f.fetching, url)
+	f.mu.Unlock()
+
+	if value != previous.value {
+		// One pending event already renders every change.
+		select {
+		case f.changed <- event.GenericEvent{}:
+		default:
+		}
+	}
+}
+
+// prune drops expired values, so that URLs no longer referenced by any
+// ingress do not accumulate. Values being fetched again are kept to be shown
+// until the fetch completes.
+func (f *cachedFetcher) prune() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	for url, cached := range f.cache {
+		if now.Sub(cached.fetchedAt) >= f.ttl && !f.fetching[url] {
+			delete(f.cache, url)
+		}
+	}
+}
+
+func (f *cachedFetcher) fetch(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	if f.allowed != nil {
+		if err := f.allowed(req.URL); err != nil {
+			return "", err
+		}
+	}
+	req.Header.Set("Accept", f.accept)
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return f.read(resp)
+}
+
+func readDescription(resp *http.Response) (string, error) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDescriptionBytes))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// readFavicon returns the favicon as a data URI. SVG icons are rejected, as
+// they can carry scripts.
+func readFavicon(resp *http.Response) (string, error) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFaviconBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(body) > maxFaviconBytes {
+		return "", errors.New("favicon too large")
+	}
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !strings.HasPrefix(contentType, "image/") {
+		contentType, _, _ = mime.ParseMediaType(http.DetectContentType(body))
+	}
+	if !strings.HasPrefix(contentType, "image/") || strings.HasPrefix(contentType, "image/svg") {
+		return "", fmt.Errorf("unsupported favicon type %q", contentType)
+	}
+	return "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(body), nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+func TestCachedFetcherFetchesInBackground(t *testing.T) {
+	release := make(chan struct{})
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		if got := r.Header.Get("Accept"); got != "text/plain" {
+			t.Errorf("got Accept %q, want text/plain", got)
+		}
+		_, _ = w.Write([]byte(" Billing service \n"))
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	f := newDescriptionFetcher(logr.Discard(), srv.Client(), time.Hour, []string{"127.0.0.1"})
+	ctx := context.Background()
+	if got := f.Get(ctx, srv.URL); got != "" {
+		t.Fatalf("got %q before the fetch completed, want no description", got)
+	}
+	if got := f.Get(ctx, srv.URL); got != "" {
+		t.Fatalf("got %q before the fetch completed, want no description", got)
+	}
+
+	release <- struct{}{}
+	select {
+	case <-f.Changed():
+	case <-time.After(5 * time.Second):
+		t.Fatal("fetch did not signal a change")
+	}
+	if got := f.Get(ctx, srv.URL); got != "Billing service" {
+		t.Errorf("got %q, want the fetched description", got)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("got %d requests, want 1 while the first is in flight or cached", got)
+	}
+}
+
+func TestCachedFetcherCachesFailures(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	f := newFaviconFetcher(logr.Discard(), srv.Client(), time.Hour)
+	ctx := context.Background()
+	f.Get(ctx, srv.URL)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		f.mu.Lock()
+		_, cached := f.cache[srv.URL]
+		f.mu.Unlock()
+		if cached {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("failed fetch was not cached")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case <-f.Changed():
+		t.Error("failed fetch signalled a change")
+	default:
+	}
+	if got := f.Get(ctx, srv.URL); got != "" {
+		t.Errorf("got %q for a failed fetch, want no icon", got)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("got %d requests, want 1 as the failure is cached", got)
+	}
+}
+
+func TestDescriptionFetcherAllowedHosts(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://internal.invalid/secret", http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte("description"))
+	}))
+	defer srv.Close()
+	f := newDescriptionFetcher(logr.Discard(), srv.Client(), time.Hour, []string{"127.0.0.*"})
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		url     string
+		wantErr string
+	}{
+		{srv.URL + "/ok", ""},
+		{srv.URL + "/redirect", "does not match --description-host"},
+		{"http://localhost/", "does not match --description-host"},
+		{"file:///etc/passwd", "unsupported scheme"},
+	} {
+		got, err := f.fetch(ctx, tc.url)
+		if tc.wantErr == "" {
+			if err != nil || got != "description" {
+				t.Errorf("fetch(%s) = %q, %v, want the description", tc.url, got, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("fetch(%s) = %q, %v, want error %q", tc.url, got, err, tc.wantErr)
+		}
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("got %d requests, want 2 to the allowed host", got)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	netv1 "k8s.io/api/networking/v1"
+	netv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// legacyIngressVersions are the pre-v1 API versions serving ingresses, in the
+// order they are preferred.
+var legacyIngressVersions = []schema.GroupVersion{
+	netv1beta1.SchemeGroupVersion,
+	extv1beta1.SchemeGroupVersion,
+}
+
+// servedLegacyIngressVersions returns the legacy ingress API versions served by
+// the cluster.
+func servedLegacyIngressVersions(mapper meta.RESTMapper) ([]schema.GroupVersion, error) {
+	var served []schema.GroupVersion
+	for _, gv := range legacyIngressVersions {
+		_, err := mapper.RESTMapping(gv.WithKind("Ingress").GroupKind(), gv.Version)
+		if meta.IsNoMatchError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to check for %s ingresses: %w", gv, err)
+		}
+		served = append(served, gv)
+	}
+	return served, nil
+}
+
+// legacyIngressTypes creates the objects and lists of each legacy version.
+var legacyIngressTypes = map[schema.GroupVersion]struct {
+	newObject func() client.Object
+	newList   func() client.ObjectList
+}{
+	netv1beta1.SchemeGroupVersion: {
+		newObject: func() client.Object { return &netv1beta1.Ingress{} },
+		newList:   func() client.ObjectList { return &netv1beta1.IngressList{} },
+	},
+	extv1beta1.SchemeGroupVersion: {
+		newObject: func() client.Object { return &extv1beta1.Ingress{} },
+		newList:   func() client.ObjectList { return &extv1beta1.IngressList{} },
+	},
+}
+
+// legacyIngressObject returns an empty object of the given legacy version, for
+// watching.
+func legacyIngressObject(gv schema.GroupVersion) client.Object {
+	return legacyIngressTypes[gv].newObject()
+}
+
+// listLegacyIngresses lists ingresses of a legacy API version, converted to v1.
+func listLegacyIngresses(ctx context.Context, kubeClient client.Reader, gv schema.GroupVersion) ([]netv1.Ingress, error) {
+	types, ok := legacyIngressTypes[gv]
+	if !ok {
+		return nil, fmt.Errorf("unsupported ingress version %s", gv)
+	}
+	list := types.newList()
+	if err := kubeClient.List(ctx, list); err != nil {
+		return nil, err
+	}
+	objs, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]netv1.Ingress, 0, len(objs))
+	for _, obj := range objs {
+		item, err := convertLegacyIngress(obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s ingress: %w", gv, err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// convertLegacyIngress converts an ingress of any legacy version to v1. The
+// legacy versions share their fields, so each is read as a networking.k8s.io
+// v1beta1 ingress first.
+func convertLegacyIngress(obj runtime.Object) (netv1.Ingress, error) {
+	fields, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return netv1.Ingress{}, err
+	}
+	var in netv1beta1.Ingress
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(fields, &in); err != nil {
+		return netv1.Ingress{}, err
+	}
+
+	out := netv1.Ingress{
+		ObjectMeta: in.ObjectMeta,
+		Spec: netv1.IngressSpec{
+			IngressClassName: in.Spec.IngressClassName,
+		},
+	}
+	if b := in.Spec.Backend; b != nil {
+		out.Spec.DefaultBackend = convertLegacyBackend(b.ServiceName, b.ServicePort, b.Resource)
+	}
+	for _, tls := range in.Spec.TLS {
+		out.Spec.TLS = append(out.Spec.TLS, netv1.IngressTLS{Hosts: tls.Hosts, SecretName: tls.SecretName})
+	}
+	for _, rule := range in.Spec.Rules {
+		outRule := netv1.IngressRule{Host: rule.Host}
+		if rule.HTTP != nil {
+			outRule.HTTP = &netv1.HTTPIngressRuleValue{}
+			for _, path := range rule.HTTP.Paths {
+				outRule.HTTP.Paths = append(outRule.HTTP.Paths, netv1.HTTPIngressPath{
+					Path:     path.Path,
+					PathType: (*netv1.PathType)(path.PathType),
+					Backend:  *convertLegacyBackend(path.Backend.ServiceName, path.Backend.ServicePort, path.Backend.Resource),
+				})
+			}
+		}
+		out.Spec.Rules = append(out.Spec.Rules, outRule)
+	}
+	for _, lb := range in.Status.LoadBalancer.Ingress {
+		out.Status.LoadBalancer.Ingress = append(out.Status.LoadBalancer.Ingress, netv1.IngressLoadBalancerIngress{IP: lb.IP, Hostname: lb.Hostname})
+	}
+	return out, nil
+}
+
+func convertLegacyBackend(serviceName string, servicePort intstr.IntOrString, resource *corev1.TypedLocalObjectReference) *netv1.IngressBackend {
+	if resource != nil {
+		return &netv1.IngressBackend{Resource: resource}
+	}
+	port := netv1.ServiceBackendPort{}
+	if servicePort.Type == intstr.String {
+		port.Name = servicePort.StrVal
+	} else {
+		port.Number = servicePort.IntVal
+	}
+	return &netv1.IngressBackend{Service: &netv1.IngressServiceBackend{Name: serviceName, Port: port}}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	netv1 "k8s.io/api/networking/v1"
+	netv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestListLegacyIngresses(t *testing.T) {
+	prefix := "Prefix"
+	class := "nginx"
+	resource := &corev1.TypedLocalObjectReference{Kind: "Bucket", Name: "assets"}
+	want := []netv1.Ingress{{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team", Annotations: map[string]string{"a": "b"}},
+		Spec: netv1.IngressSpec{
+			IngressClassName: &class,
+			DefaultBackend:   &netv1.IngressBackend{Resource: resource},
+			TLS:              []netv1.IngressTLS{{Hosts: []string{"app.example.com"}, SecretName: "tls"}},
+			Rules: []netv1.IngressRule{{
+				Host: "app.example.com",
+				IngressRuleValue: netv1.IngressRuleValue{HTTP: &netv1.HTTPIngressRuleValue{Paths: []netv1.HTTPIngressPath{{
+					Path:     "/api",
+					PathType: (*netv1.PathType)(&prefix),
+					Backend:  netv1.IngressBackend{Service: &netv1.IngressServiceBackend{Name: "api", Port: netv1.ServiceBackendPort{Number: 8080}}},
+				}, {
+					Path:    "/web",
+					Backend: netv1.IngressBackend{Service: &netv1.IngressServiceBackend{Name: "web", Port: netv1.ServiceBackendPort{Name: "http"}}},
+				}}}},
+			}},
+		},
+		Status: netv1.IngressStatus{LoadBalancer: netv1.IngressLoadBalancerStatus{
+			Ingress: []netv1.IngressLoadBalancerIngress{{IP: "10.0.0.1"}},
+		}},
+	}}
+
+	meta := metav1.ObjectMeta{Name: "app", Namespace: "team", Annotations: map[string]string{"a": "b"}}
+	for _, tc := range []struct {
+		gv  schema.GroupVersion
+		obj client.Object
+	}{{
+		gv: netv1beta1.SchemeGroupVersion,
+		obj: &netv1beta1.Ingress{
+			ObjectMeta: meta,
+			Spec: netv1beta1.IngressSpec{
+				IngressClassName: &class,
+				Backend:          &netv1beta1.IngressBackend{Resource: resource},
+				TLS:              []netv1beta1.IngressTLS{{Hosts: []string{"app.example.com"}, SecretName: "tls"}},
+				Rules: []netv1beta1.IngressRule{{
+					Host: "app.example.com",
+					IngressRuleValue: netv1beta1.IngressRuleValue{HTTP: &netv1beta1.HTTPIngressRuleValue{Paths: []netv1beta1.HTTPIngressPath{{
+						Path:     "/api",
+						PathType: (*netv1beta1.PathType)(&prefix),
+						Backend:  netv1beta1.IngressBackend{ServiceName: "api", ServicePort: intstr.FromInt32(8080)},
+					}, {
+						Path:    "/web",
+						Backend: netv1beta1.IngressBackend{ServiceName: "web", ServicePort: intstr.FromString("http")},
+					}}}},
+				}},
+			},
+			Status: netv1beta1.IngressStatus{LoadBalancer: netv1beta1.IngressLoadBalancerStatus{
+				Ingress: []netv1beta1.IngressLoadBalancerIngress{{IP: "10.0.0.1"}},
+			}},
+		},
+	}, {
+		gv: extv1beta1.SchemeGroupVersion,
+		obj: &extv1beta1.Ingress{
+			ObjectMeta: meta,
+			Spec: extv1beta1.IngressSpec{
+				IngressClassName: &class,
+				Backend:          &extv1beta1.IngressBackend{Resource: resource},
+				TLS:              []extv1beta1.IngressTLS{{Hosts: []string{"app.example.com"}, SecretName: "tls"}},
+				Rules: []extv1beta1.IngressRule{{
+					Host: "app.example.com",
+					IngressRuleValue: extv1beta1.IngressRuleValue{HTTP: &extv1beta1.HTTPIngressRuleValue{Paths: []extv1beta1.HTTPIngressPath{{
+						Path:     "/api",
+						PathType: (*extv1beta1.PathType)(&prefix),
+						Backend:  extv1beta1.IngressBackend{ServiceName: "api", ServicePort: intstr.FromInt32(8080)},
+					}, {
+						Path:    "/web",
+						Backend: extv1beta1.IngressBackend{ServiceName: "web", ServicePort: intstr.FromString("http")},
+					}}}},
+				}},
+			},
+			Status: extv1beta1.IngressStatus{LoadBalancer: extv1beta1.IngressLoadBalancerStatus{
+				Ingress: []extv1beta1.IngressLoadBalancerIngress{{IP: "10.0.0.1"}},
+			}},
+		},
+	}} {
+		t.Run(tc.gv.String(), func(t *testing.T) {
+			kubeClient := fake.NewClientBuilder().WithObjects(tc.obj).WithStatusSubresource().Build()
+			got, err := listLegacyIngresses(context.Background(), kubeClient, tc.gv)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The fake client sets the resource version.
+			for i := range got {
+				got[i].ResourceVersion = ""
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("converted ingresses differ (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"text/tabwriter"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+)
+
+// listedHost is a host as output by the list subcommand.
+type listedHost struct {
+	Host      string   `json:"host"`
+	Namespace string   `json:"namespace,omitempty"`
+	URL       string   `json:"url"`
+	Group     string   `json:"group,omitempty"`
+	Paths     []string `json:"paths,omitempty"`
+	// UID and ResourceVersion are only set with -include-provenance.
+	UID             string `json:"uid,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+// listLinks implements the list subcommand, which runs a single reconcile
+// against the cluster and prints the hosts the page would show. It returns
+// the process exit code.
+func listLinks(log logr.Logger, args []string) int {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	kubeContext := flags.String("context", "", "Context from kubeconfig to use, if not the selected context")
+	output := flags.String("o", "table", "Output format - one of table, json, yaml")
+	includeProvenance := flags.Bool("include-provenance", false, "Include the UID and resource version of the ingress declaring each host")
+	// The controller's flags for collecting links apply to the listing too.
+	opts := addLinkFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *output != "table" && *output != "json" && *output != "yaml" {
+		fmt.Fprintln(flags.Output(), "-o must be one of table, json, yaml")
+		flags.Usage()
+		return 2
+	}
+
+	kubeConf, err := config.GetConfigWithContext(*kubeContext)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get kubeconfig: %v\n", err)
+		return 1
+	}
+	kubeClient, err := client.New(kubeConf, client.Options{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create client: %v\n", err)
+		return 1
+	}
+	tpl, err := srvTpl.Clone()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to clone templates: %v\n", err)
+		return 1
+	}
+
+	// Running the reconciler keeps the listing in line with the page.
+	var pagePtr atomic.Pointer[renderSnapshot]
+	opts.includeProvenance = *includeProvenance
+	if _, err := buildReconciler(log, kubeClient, &pagePtr, tpl, *opts).Reconcile(context.Background(), reconcile.Request{}); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to collect links: %v\n", err)
+		return 1
+	}
+
+	var hosts []listedHost
+	for _, hv := range pagePtr.Load().Hosts {
+		listed := listedHost{Host: hv.Host, Namespace: hv.Namespace, URL: hv.URL, Group: hv.Group, UID: hv.UID, ResourceVersion: hv.ResourceVersion}
+		for _, pv := range sortedPaths(hv) {
+			listed.Paths = append(listed.Paths, pv.Path)
+		}
+		hosts = append(hosts, listed)
+	}
+	if err := writeListedHosts(os.Stdout, hosts, *output, *includeProvenance); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func writeListedHosts(w io.Writer, hosts []listedHost, output string, provenance bool) error {
+	switch output {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(hosts)
+	case "yaml":
+		data, err := yaml.Marshal(hosts)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 3, ' ', 0)
+	if provenance {
+		fmt.Fprintln(tw, "HOST\tNAMESPACE\tPATHS\tGROUP\tUID\tRESOURCE VERSION")
+	} else {
+		fmt.Fprintln(tw, "HOST\tNAMESPACE\tPATHS\tGROUP")
+	}
+	for _, hv := range hosts {
+		if provenance {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", hv.Host, hv.Namespace, strings.Join(hv.Paths, ","), hv.Group, hv.UID, hv.ResourceVersion)
+		} else {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", hv.Host, hv.Namespace, strings.Join(hv.Paths, ","), hv.Group)
+		}
+	}
+	return tw.Flush()
+}
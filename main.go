@@ -1,7 +1,12 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -9,49 +14,323 @@ import (
 	"io"
 	"log/slog"
 	"maps"
+	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"slices"
-	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"text/tabwriter"
+	texttemplate "text/template"
 	"time"
 	"unicode"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	corev1 "k8s.io/api/core/v1"
 	netv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	ctrlconfig "sigs.k8s.io/controller-runtime/pkg/config"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/yaml"
 )
 
 type templateValues struct {
-	Hosts []*hostValues
+	Hosts   []*hostValues
+	Groups  []*groupValues
+	Options pageOptions
+	// TotalHosts and TotalPaths count the links before any truncation.
+	TotalHosts int
+	TotalPaths int
+	// MoreHosts counts the hosts left out of Hosts by the link cap.
+	MoreHosts int
+	// Confirm is set if any rendered link asks for confirmation.
+	Confirm bool
+	// TOC lists the named groups in the order they are rendered, if enabled.
+	TOC []*groupValues
+	// Schemes is set if any rendered host links to several schemes.
+	Schemes bool
+	// Hostless is set if any rendered host is an ingress without a host.
+	Hostless bool
+	// DefaultBackends lists the hosts of ingresses with only a default
+	// backend, which are rendered apart from the groups.
+	DefaultBackends []*hostValues
+}
+
+// RenderTemplate renders the named template with the same values, for
+// templates that embed others such as the tabs.
+func (v *templateValues) RenderTemplate(name string) (template.HTML, error) {
+	var sb strings.Builder
+	if err := srvTpl.ExecuteTemplate(&sb, name, v); err != nil {
+		return "", err
+	}
+	return template.HTML(sb.String()), nil
+}
+
+// pageOptions are the flags that affect how the page template renders.
+type pageOptions struct {
+	ThemeToggle bool
+	// QR adds the styles for hosts with QR codes.
+	QR bool
+	// Descriptions adds the styles for host descriptions.
+	Descriptions bool
+	// ShowNamespace adds the styles for namespace labels.
+	ShowNamespace bool
+	// TOC renders a list of links to the groups above them.
+	TOC bool
+	// Favicons adds the styles for host favicons.
+	Favicons bool
+	// Pinned links are rendered first, in the order given.
+	Pinned []extraLink
+	// ExtraHead is trusted HTML added to the end of the head.
+	ExtraHead template.HTML
+	// MaxPathsPerHost truncates each host's paths if non-zero, linking to
+	// the host's page at HostDetailPrefix, or host/ if empty, followed by the
+	// host.
+	MaxPathsPerHost  int
+	HostDetailPrefix string
+	// MaxLinks caps the number of hosts rendered if non-zero.
+	MaxLinks int
+	// GroupOrder lists group paths to show first, in order.
+	GroupOrder []string
+	// DefaultGroupName is the heading of the hosts without a group, if set,
+	// and DefaultGroupLast moves them after the named groups.
+	DefaultGroupName string
+	DefaultGroupLast bool
+	// Tabs replaces the links with a tab for each template if set.
+	Tabs []pageTab
+	// Minify removes the whitespace between tags from rendered pages.
+	Minify bool
+	// OpenAll adds a button to each group opening all of its links.
+	OpenAll bool
+	// Config holds the data of the --config-map as of the render, if set.
+	Config map[string]string
+}
+
+// pageTab is a tab on the page, showing the output of a template.
+type pageTab struct {
+	Name     string
+	Template string
+}
+
+// groupValues holds the hosts of a group in sorted order, followed by its
+// subgroups in alphabetical order. Hosts without a group are collected in a
+// leading group with an empty name.
+type groupValues struct {
+	// Name is the last segment of the group's path, Path the full path.
+	Name  string
+	Path  string
+	Depth int
+	// Anchor is the unique fragment identifier of the group's heading, set if
+	// the table of contents is enabled.
+	Anchor string
+	// OpenAllURLs are the space-separated URLs opened by the group's open
+	// all button, set if the button is enabled.
+	OpenAllURLs string
+	Hosts       []*hostValues
+	Groups      []*groupValues
 }
 
 type hostValues struct {
-	Host  string
-	Text  template.HTML
+	Host string
+	// DisplayHost is the Host with the --strip-suffix removed, shown instead
+	// of the Host if there is no Text.
+	DisplayHost string
+	// Namespace of the ingress that first declared the host, empty for static
+	// links.
+	Namespace string
+	// Class is the ingress class of the ingress that first declared the host.
+	Class string
+	// CreatedAt is the creation time of the oldest ingress declaring the
+	// host, zero for static links.
+	CreatedAt time.Time
+	// Port is the port annotation, left out of links where it is the standard
+	// port for the scheme.
+	Port string
+	// Insecure links use http:// rather than https://.
+	Insecure bool
+	// Schemes lists the schemes to link to the host with, if several, with
+	// the first used for URL.
+	Schemes []string
+	URL     string
+	Text    template.HTML
+	Group   string
+	// SortKey replaces Host when sorting, if set.
+	SortKey string
+	// Primary hosts are sorted first and rendered more prominently.
+	Primary bool
+	// Compact hosts are listed on the compact page for small screens.
+	Compact bool
+	// Order sorts hosts before falling back to the domain, lowest first.
+	Order int
+	// Pin is "top" or "bottom" to sort the host before or after all others,
+	// if set.
+	Pin   string
 	Paths map[string]*pathValues
+	// Collapse renders the paths inside a disclosure element.
+	Collapse bool
+	// MorePaths counts the paths left out of Paths by truncation, and
+	// DetailURL links to the host's page listing all of them.
+	MorePaths int
+	DetailURL string
+	// Data holds data-* attributes for the host link, keyed by the name after
+	// the data- prefix.
+	Data map[string]string
+	// Confirm is a message to confirm before following the link, if set.
+	Confirm string
+	// Tooltip is shown when hovering the link, if set.
+	Tooltip string
+	// QR is an inline SVG QR code of the URL, if enabled.
+	QR template.HTML
+	// Description is fetched from the description-url annotation, if enabled.
+	Description string
+	// Icon is a data URI of the host's favicon, if enabled and found.
+	Icon template.URL
+	// NamespaceLabel is the Namespace, if --show-namespace is set.
+	NamespaceLabel string
+	// Rel is the rel attribute for the links of the host's group, if set.
+	Rel string
+	// ExtraURLs are non-HTTP links listed under the host, such as ssh://.
+	ExtraURLs []template.URL
+	// Source is the name of the remote cluster of the ingress that first
+	// declared the host, empty for the local cluster.
+	Source string
+	// DefaultBackend describes the backend of an ingress with only a default
+	// backend, if the host was added for one.
+	DefaultBackend string
+	// Hostless entries list an ingress without a host under its namespace
+	// and name, and have no URL.
+	Hostless bool
+	// UID and ResourceVersion identify the ingress that first declared the
+	// host, if --include-provenance is set.
+	UID             string
+	ResourceVersion string
+
+	// AllowedGroups restricts which forwarded groups may see the host, with
+	// nil meaning it is visible to everyone.
+	AllowedGroups []string
+}
+
+// SchemeURL returns the URL of the host with the scheme.
+func (hv *hostValues) SchemeURL(scheme string) string {
+	return hostURL(hv.Host, hv.Port, scheme == "http")
 }
 
 type hostTemplateValue struct {
 	Host    string
 	Ingress *netv1.Ingress
 	Rule    *netv1.IngressRule
+	// TLSHosts lists the hosts of the ingress's TLS entry covering the host,
+	// such as the other names of its certificate, or nil if there is none.
+	TLSHosts []string
 }
 
 type pathValues struct {
-	Host string
+	Host      string
+	Namespace string
+	Port      string
+	Path      string
+	// PathType is the type of the ingress path, Exact or Prefix.
+	PathType string
+	URL      string
+	Text     template.HTML
+	Confirm  string
+	// NamespaceLabel is the Namespace if --show-namespace is set and it
+	// differs from the host's, as hosts can be declared in several namespaces.
+	NamespaceLabel string
+	// Rel is the rel attribute of the host's links.
+	Rel string
+	// Heading lists the path under a heading within its host, if set.
+	Heading string
+	// Tooltip is shown when hovering the link, if set.
+	Tooltip string
+
+	AllowedGroups []string
+
+	// defaultText is set if the Text isn't from a path template.
+	defaultText bool
+}
+
+// headingValues holds the paths of a host listed under a heading.
+type headingValues struct {
+	Name  string
+	Paths []*pathValues
+}
+
+// Headings groups the paths that have a heading by heading, in the order they
+// are rendered.
+func (hv *hostValues) Headings() []headingValues {
+	var headings []headingValues
+	for _, pv := range sortedPaths(hv) {
+		if pv.Heading == "" {
+			continue
+		}
+		i := slices.IndexFunc(headings, func(h headingValues) bool { return h.Name == pv.Heading })
+		if i < 0 {
+			i = len(headings)
+			headings = append(headings, headingValues{Name: pv.Heading})
+		}
+		headings[i].Paths = append(headings[i].Paths, pv)
+	}
+	slices.SortStableFunc(headings, func(a, b headingValues) int { return strings.Compare(a.Name, b.Name) })
+	return headings
+}
+
+// pathNode is a segment of a host's path tree.
+type pathNode struct {
+	// Segment is the last segment of the node's path.
+	Segment string
+	// Path is the path up to and including the segment.
 	Path string
-	Text template.HTML
+	// Links are the host's paths ending at the segment, none for a segment
+	// that only leads to other paths and several for paths that only differ
+	// in a trailing slash or their type.
+	Links    []*pathValues
+	Children []*pathNode
+}
+
+// PathTree arranges the paths of the host by their segments, e.g. /app/admin
+// under /app, for templates to render nested lists. Children are in the order
+// their first paths are rendered.
+func (hv *hostValues) PathTree() []*pathNode {
+	root := &pathNode{}
+	for _, pv := range sortedPaths(hv) {
+		node := root
+		for _, segment := range strings.Split(strings.Trim(pv.Path, "/"), "/") {
+			i := slices.IndexFunc(node.Children, func(child *pathNode) bool { return child.Segment == segment })
+			if i < 0 {
+				i = len(node.Children)
+				node.Children = append(node.Children, &pathNode{Segment: segment, Path: node.Path + "/" + segment})
+			}
+			node = node.Children[i]
+		}
+		node.Links = append(node.Links, pv)
+	}
+	return root.Children
 }
 
 type pathTemplateValue struct {
@@ -68,43 +347,723 @@ var srvTpl = template.Must(template.New("").Parse(`<!DOCTYPE html>
 		html { height: 100%; }
 		body { margin: 0; height: 100%; display: flex; font-family: sans-serif; color-scheme: light dark; background-color: Canvas; }
 		#links { margin: auto; padding: 10px; border-radius: 10px; background-color: light-dark(#eee,#333); }
-		a { display: block; margin: 2px; text-align: right; }
+		#links { max-width: 100%; box-sizing: border-box; }
+		a { display: block; margin: 2px; text-align: right; overflow-wrap: anywhere; }
+		h2 { margin: 8px 2px 2px; font-size: 1em; text-align: right; }
+		h3.heading { margin: 4px 2px 2px; font-size: 0.875em; text-align: right; }
+		section.group section.group { margin-right: 1em; }
+		summary a { display: inline; }
+		a.primary { font-size: 1.25em; font-weight: bold; }
+		{{- if .Options.Tabs }}
+		#tabs { margin: auto; }
+		#tabs nav { text-align: right; }
+		#tabs nav a { display: inline; }
+		#tabs .tab { display: none; }
+		#tabs .tab:first-of-type, #tabs .tab:target { display: block; }
+		#tabs:has(.tab:target) .tab:first-of-type:not(:target) { display: none; }
+		{{- end}}
+		{{- if .Options.QR }}
+		svg.qr { display: block; width: 8em; height: 8em; margin: 2px 2px 2px auto; }
+		{{- end}}
+		{{- if .Options.ShowNamespace }}
+		span.namespace { margin-left: 0.5em; padding: 0 0.25em; border-radius: 4px; font-size: 0.75em; background-color: light-dark(#ddd,#555); }
+		{{- end}}
+		{{- if .Schemes }}
+		span.schemes { display: block; margin: 0 2px; text-align: right; font-size: 0.75em; }
+		span.schemes a { display: inline; }
+		{{- end}}
+		{{- if .Hostless }}
+		span.hostless { display: block; margin: 2px; text-align: right; opacity: 0.7; }
+		{{- end}}
+		{{- if .Options.Pinned }}
+		nav.pinned { margin-bottom: 8px; padding-bottom: 4px; border-bottom: 1px solid light-dark(#ccc,#555); }
+		{{- end}}
+		{{- if .Options.Favicons }}
+		img.icon { width: 1em; height: 1em; margin-right: 0.25em; vertical-align: middle; }
+		{{- end}}
+		{{- if .Options.TOC }}
+		nav.toc { margin-bottom: 8px; }
+		{{- end}}
+		{{- if .Options.Descriptions }}
+		p.description { margin: 0 2px 4px; text-align: right; font-size: 0.875em; opacity: 0.8; }
+		{{- end}}
+		{{- if .Options.ThemeToggle }}
+		html.light body { color-scheme: light; }
+		html.dark body { color-scheme: dark; }
+		#theme-toggle { position: fixed; top: 10px; right: 10px; }
+		{{- end}}
+		{{- if .Options.OpenAll }}
+		button.open-all { display: block; margin: 2px 2px 2px auto; }
+		{{- end}}
 		{{- end}}
 	</style>
+	{{- block "extra-head" .}}{{with .Options.ExtraHead}}
+	{{.}}{{end}}{{end}}
 	{{- end}}
 </head>
 <body>
 	{{- block "body" .}}
-	<div id="links">
-	{{- range .Hosts }}
-		{{block "hostlink" .}}<a class="host" href="https://{{.Host}}">{{or .Text .Host}}</a>{{end}}
+	{{- if .Options.Tabs }}
+	{{block "tabs" .}}<div id="tabs">
+		<nav>
+		{{- range $i, $tab := .Options.Tabs }}
+			<a href="#tab-{{$i}}">{{$tab.Name}}</a>
+		{{- end}}
+		</nav>
+		{{- range $i, $tab := .Options.Tabs }}
+		<section class="tab" id="tab-{{$i}}">{{$.RenderTemplate $tab.Template}}</section>
+		{{- end}}
+	</div>{{end}}
+	{{- else }}
+	{{block "links" .}}<div id="links">
+	{{- with .Options.Pinned }}
+		{{block "pinned" .}}<nav class="pinned">
+		{{- range .}}
+			<a class="pinned" href="{{.URL}}">{{or .Text .Host}}</a>
+		{{- end}}
+		</nav>{{end}}
+	{{- end}}
+	{{- with .TOC }}
+		{{block "toc" .}}<nav class="toc">
+		{{- range .}}
+			<a href="#{{.Anchor}}" style="margin-right: {{.Depth}}em">{{.Name}}</a>
+		{{- end}}
+		</nav>{{end}}
+	{{- end}}
+	{{- range .Groups }}
+		{{- block "group" .}}
+		{{- if .Name }}
+		<section class="group">
+		{{block "grouphead" .}}<h2 class="group"{{with .Anchor}} id="{{.}}"{{end}}>{{.Name}}</h2>{{end}}
+		{{- end}}
+		{{- with .OpenAllURLs }}
+		<button class="open-all" type="button" data-links="{{.}}">Open all</button>
+		{{- end}}
+		{{- range .Hosts }}
+		{{- if .Collapse }}
+		<details class="host">
+			<summary>{{template "hostlink" .}}</summary>
+			{{- if gt (len .Schemes) 1 }}
+			{{template "schemelinks" .}}
+			{{- end}}
+			{{- with .Description }}
+			<p class="description">{{.}}</p>
+			{{- end}}
+			{{- with .QR }}
+			{{.}}
+			{{- end}}
+			{{- template "pathlinks" .}}
+		</details>
+		{{- else }}
+		{{block "hostlink" .}}{{if .Hostless}}<span class="host hostless"{{with .UID}} data-uid="{{.}}"{{end}}{{with .ResourceVersion}} data-resource-version="{{.}}"{{end}}{{with .Tooltip}} title="{{.}}"{{end}}>{{or .Text .DisplayHost .Host}}</span>{{else}}<a class="host{{if .Primary}} primary{{end}}"{{range $name, $value := .Data}} data-{{$name}}="{{$value}}"{{end}}{{with .Source}} data-source="{{.}}"{{end}}{{with .UID}} data-uid="{{.}}"{{end}}{{with .ResourceVersion}} data-resource-version="{{.}}"{{end}}{{with .Confirm}} data-confirm="{{.}}"{{end}}{{with .Rel}} rel="{{.}}"{{end}}{{with .Tooltip}} title="{{.}}"{{end}} href="{{.URL}}">{{with .Icon}}<img class="icon" src="{{.}}" alt="">{{end}}{{or .Text .DisplayHost .Host}}{{with .NamespaceLabel}}<span class="namespace">{{.}}</span>{{end}}</a>{{end}}{{end}}
+		{{- if gt (len .Schemes) 1 }}
+		{{block "schemelinks" .}}<span class="schemes">
+			{{- range .Schemes }} <a class="scheme" href="{{$.SchemeURL .}}">{{.}}</a>{{end -}}
+		</span>{{end}}
+		{{- end}}
+		{{- with .Description }}
+		<p class="description">{{.}}</p>
+		{{- end}}
+		{{- with .QR }}
+		{{.}}
+		{{- end}}
+		{{- block "pathlinks" .}}
 		{{- range .Paths -}}
-			{{- if ne .Path "/" }}
-			{{block "pathlink" .}}<a class="path" href="https://{{.Host}}{{.Path}}">{{or .Text .Path}}</a>{{end}}
+			{{- if and (ne .Path "/") (not .Heading) }}
+			{{block "pathlink" .}}<a class="path"{{with .Confirm}} data-confirm="{{.}}"{{end}}{{with .Rel}} rel="{{.}}"{{end}}{{with .Tooltip}} title="{{.}}"{{end}} href="{{.URL}}">{{or .Text .Path}}{{with .NamespaceLabel}}<span class="namespace">{{.}}</span>{{end}}</a>{{end}}
 			{{- end -}}
 		{{end -}}
-	{{end}}
-	</div>
+		{{- range .Headings }}
+			<h3 class="heading">{{.Name}}</h3>
+			{{- range .Paths }}
+			{{template "pathlink" .}}
+			{{- end}}
+		{{- end}}
+		{{- with .ExtraURLs }}
+			{{block "extraurls" .}}<div class="extra-urls">
+			{{- range .}}
+				<a class="extra" href="{{.}}">{{.}}</a>
+			{{- end}}
+			</div>{{end}}
+		{{- end}}
+		{{- if .MorePaths }}
+			<a class="more" href="{{.DetailURL}}">+{{.MorePaths}} more</a>
+		{{- end -}}
+		{{end -}}
+		{{end -}}
+		{{end -}}
+		{{- range .Groups }}{{template "group" .}}{{end}}
+		{{- if .Name }}
+		</section>
+		{{- end}}
+		{{- end}}
+	{{- end}}
+	{{- with .DefaultBackends }}
+		{{block "defaultbackends" .}}<section class="default-backends">
+		<h2>Default backends</h2>
+		{{- range .}}
+		{{template "hostlink" .}}
+		{{- end}}
+		</section>{{end}}
 	{{- end}}
+	{{- if .MoreHosts }}
+		<p class="more">+{{.MoreHosts}} more links not shown</p>
+	{{- end}}
+	</div>{{end}}
+	{{- end}}
+	{{- if .Options.ThemeToggle }}
+	{{block "themetoggle" .}}<button id="theme-toggle" type="button">Toggle theme</button>
+	<script>
+		(function () {
+			var root = document.documentElement;
+			var stored = localStorage.getItem("theme");
+			if (stored === "light" || stored === "dark") {
+				root.classList.add(stored);
+			}
+			document.getElementById("theme-toggle").addEventListener("click", function () {
+				var dark = root.classList.contains("dark") ||
+					(!root.classList.contains("light") && matchMedia("(prefers-color-scheme: dark)").matches);
+				var theme = dark ? "light" : "dark";
+				root.classList.remove("light", "dark");
+				root.classList.add(theme);
+				localStorage.setItem("theme", theme);
+			});
+		})();
+	</script>{{end}}
+	{{- end}}
+	{{- if .Confirm }}
+	{{block "confirmscript" .}}<script>
+		document.addEventListener("click", function (event) {
+			var link = event.target.closest("a[data-confirm]");
+			if (link && !confirm(link.dataset.confirm)) {
+				event.preventDefault();
+			}
+		});
+	</script>{{end}}
+	{{- end}}
+	{{- if .Options.OpenAll }}
+	{{block "openallscript" .}}<script>
+		document.addEventListener("click", function (event) {
+			var button = event.target.closest("button.open-all");
+			if (button) {
+				button.dataset.links.split(" ").forEach(function (url) {
+					window.open(url, "_blank", "noopener");
+				});
+			}
+		});
+	</script>{{end}}
+	{{- end}}
+	{{- end}}
+</body>
+</html>
+{{define "hostdetail"}}<!DOCTYPE html>
+<html>
+<head>
+	{{- template "head" .}}
+</head>
+<body>
+	<div id="links">
+	{{- range .Hosts }}
+		{{template "hostlink" .}}
+		{{- template "pathlinks" .}}
+	{{- end}}
+	</div>
 </body>
 </html>
-`))
+{{end}}`))
+
+// faviconTTL is how long fetched favicons, or failures to fetch them, are
+// cached for.
+const faviconTTL = 24 * time.Hour
 
-const (
-	hostTemplateAnnotation = "ingress-links.nev.dev/host-template"
-	pathTemplateAnnotation = "ingress-links.nev.dev/path-template"
-	skipAnnotation         = "ingress-links.nev.dev/skip"
+// minResyncPeriod bounds --resync-period to avoid re-rendering in a busy loop.
+const minResyncPeriod = 10 * time.Second
+
+const defaultAnnotationPrefix = "ingress-links.nev.dev/"
+
+// defaultSkipPathPrefixes are left off every host unless
+// --global-skip-path-prefix is set, as they are for machines rather than
+// people.
+var defaultSkipPathPrefixes = []string{"/.well-known"}
+
+// Annotation keys, derived from the --annotation-prefix by
+// setAnnotationPrefix.
+var (
+	annotationPrefix           string
+	hostTemplateAnnotation     string
+	hostTemplateNameAnnotation string
+	pathTemplateAnnotation     string
+	skipAnnotation             string
+	allowedGroupsAnnotation    string
+	collapsePathsAnnotation    string
+	groupAnnotation            string
+	portAnnotation             string
+	sortKeyAnnotation          string
+	primaryAnnotation          string
+	orderAnnotation            string
+	compactAnnotation          string
+	expiresAnnotation          string
+	confirmAnnotation          string
+	descriptionURLAnnotation   string
+	insecureAnnotation         string
+	headingAnnotation          string
+	extraURLAnnotation         string
+	tooltipAnnotation          string
+	schemesAnnotation          string
+	pinAnnotation              string
+	// Annotations with this prefix are rendered as data-* attributes.
+	dataAnnotationPrefix string
 )
 
+func init() {
+	setAnnotationPrefix(defaultAnnotationPrefix)
+}
+
+func setAnnotationPrefix(prefix string) {
+	annotationPrefix = prefix
+	hostTemplateAnnotation = prefix + "host-template"
+	hostTemplateNameAnnotation = prefix + "host-template-name"
+	pathTemplateAnnotation = prefix + "path-template"
+	skipAnnotation = prefix + "skip"
+	allowedGroupsAnnotation = prefix + "allowed-groups"
+	collapsePathsAnnotation = prefix + "collapse-paths"
+	groupAnnotation = prefix + "group"
+	portAnnotation = prefix + "port"
+	sortKeyAnnotation = prefix + "sort-key"
+	primaryAnnotation = prefix + "primary"
+	orderAnnotation = prefix + "order"
+	compactAnnotation = prefix + "compact"
+	expiresAnnotation = prefix + "expires"
+	confirmAnnotation = prefix + "confirm"
+	descriptionURLAnnotation = prefix + "description-url"
+	insecureAnnotation = prefix + "insecure"
+	headingAnnotation = prefix + "heading"
+	extraURLAnnotation = prefix + "extra-url"
+	tooltipAnnotation = prefix + "tooltip"
+	schemesAnnotation = prefix + "schemes"
+	pinAnnotation = prefix + "pin"
+	dataAnnotationPrefix = prefix + "data-"
+}
+
+// renderSnapshot is the result of a reconcile, swapped in atomically so that
+// request handlers always see a consistent view.
+type renderSnapshot struct {
+	// Generation counts the renders since the controller started, and
+	// RenderedAt is the time of this render.
+	Generation uint64
+	RenderedAt time.Time
+
+	Page string
+	// Views holds the pages rendered from the --view templates, by name.
+	Views map[string]string
+	// Classes holds the page rendered for each ingress class, if split.
+	Classes map[string]string
+	// Compact is the page rendered with only the compact hosts.
+	Compact string
+	Hosts   []*hostValues
+	// HostsByName indexes Hosts by their host name.
+	HostsByName map[string]*hostValues
+	// Restricted is set if any link has AllowedGroups, in which case the page
+	// must be rendered per-request instead of serving Page.
+	Restricted bool
+	// ContentHash covers everything served from the snapshot, so that renders
+	// that change nothing can keep the previous snapshot.
+	ContentHash [sha256.Size]byte
+	// Config is the data of the --config-map the pages were rendered with.
+	Config map[string]string
+}
+
+// pageOptions returns the page options with the snapshot's config map data,
+// for rendering pages per request the same way as the reconciler.
+func (s *renderSnapshot) pageOptions(opts pageOptions) pageOptions {
+	opts.Config = s.Config
+	return opts
+}
+
+// visibleTo returns the hosts visible to the user making a request, which is
+// all of them unless the snapshot is restricted. Every output format filters
+// hosts through it, so that they all apply the same rules.
+func (s *renderSnapshot) visibleTo(req *http.Request) []*hostValues {
+	if !s.Restricted {
+		return s.Hosts
+	}
+	return visibleHosts(s.Hosts, forwardedGroups(req))
+}
+
+// extraLink is a static link entry loaded from an --extra-links file, for
+// services that are not exposed through an ingress.
+type extraLink struct {
+	Host  string `json:"host"`
+	URL   string `json:"url"`
+	Text  string `json:"text"`
+	Group string `json:"group"`
+}
+
+type reconcilerOptions struct {
+	extraLinks  []extraLink
+	collapseWWW bool
+	// onlyReady skips ingresses without a load balancer address.
+	onlyReady bool
+	// requirePaths skips hosts without paths other than the root.
+	requirePaths bool
+	// prettifyPathText derives the text of paths without a path template
+	// from the path, such as Grafana for /grafana.
+	prettifyPathText bool
+	// maxTemplateSize skips host and path template annotations longer than
+	// this many bytes, if non-zero.
+	maxTemplateSize int
+	// includeDefaultBackend adds ingresses with only a default backend,
+	// linking to defaultBackendHost or else their load balancer address.
+	includeDefaultBackend bool
+	defaultBackendHost    string
+	// showHostless lists ingresses with rules without a host, or with a
+	// default backend that isn't otherwise listed, under their name.
+	showHostless bool
+	// hostAllow and hostDeny are glob patterns filtering the hosts shown.
+	hostAllow []string
+	hostDeny  []string
+	// normalizeHosts merges hosts differing only in case and sorts them
+	// case-insensitively.
+	normalizeHosts bool
+	// qr is "all" or "primary" to render QR codes for those hosts.
+	qr string
+	// splitByClass additionally renders a page per ingress class.
+	splitByClass bool
+	// showNamespace labels links with the namespace of their ingress.
+	showNamespace bool
+	// includeProvenance records the UID and resource version of the ingress
+	// declaring each host.
+	includeProvenance bool
+	// pathCollision is how an Exact and a Prefix path with the same path are
+	// listed - one of merge, prefer-exact, prefer-prefix.
+	pathCollision string
+	// skipPathPrefixes are path prefixes left off every host, matched by
+	// path element.
+	skipPathPrefixes []string
+	// groupRel maps group paths to the rel attribute of their links.
+	groupRel map[string]string
+	// stripSuffix is removed from hosts to display them, if set.
+	stripSuffix string
+	// groupByLabel lists the labels to group ingresses without a group
+	// annotation by, using the first that is set.
+	groupByLabel []string
+	page         pageOptions
+	views        []string
+	// resyncPeriod re-renders the page periodically if non-zero.
+	resyncPeriod time.Duration
+	// legacyIngressVersions are listed in addition to v1 ingresses.
+	legacyIngressVersions []schema.GroupVersion
+	// liveReader lists ingresses for the debug endpoint's reconciles, if set.
+	liveReader client.Reader
+	// remoteClusters are listed in addition to the local cluster.
+	remoteClusters []remoteCluster
+	// configMap is read for the page templates on each render, if set.
+	configMap types.NamespacedName
+	// notifier is sent the changed hosts when the page changes, if set.
+	notifier *notifier
+	// feed records the changed hosts for the Atom feed, if set.
+	feed *linkFeed
+	// renderFailures counts consecutive failed renders, if set.
+	renderFailures *atomic.Int32
+	// descriptions fetches description-url annotations, if enabled.
+	descriptions *cachedFetcher
+	// favicons fetches the favicon of each host, if enabled.
+	favicons *cachedFetcher
+}
+
+type serverOptions struct {
+	page pageOptions
+	// pagePath is the path the page is served on, matched exactly if it ends
+	// with a slash.
+	pagePath             string
+	defaultRedirect      string
+	requireForwardedUser bool
+	// tlsConfig enables serving the page over TLS if set.
+	tlsConfig *tls.Config
+	// robotsTxt is served at /robots.txt, disallowing all crawling if empty.
+	robotsTxt []byte
+	// feed is served at /feed.atom, if set.
+	feed *linkFeed
+	// sitemap serves the link URLs at /sitemap.xml.
+	sitemap bool
+	// embedFrameAncestors are the CSP sources allowed to frame the page body
+	// served at /embed, which is only served if there are any.
+	embedFrameAncestors []string
+	// unifiedPort serves the metrics and the health and ready checks
+	// alongside the page.
+	unifiedPort  bool
+	healthChecks map[string]healthz.Checker
+	readyChecks  map[string]healthz.Checker
+	// debugReconcile re-renders the page from the API server for the debug
+	// endpoint, which is served if it is set, using debugReader to get the
+	// ingress to report on.
+	debugReconcile func(context.Context) error
+	debugReader    client.Reader
+}
+
+// addLinkFlags registers the flags that decide which links are collected from
+// ingresses and how, on the controller's flags and the list subcommand's, so
+// that list shows the links the page would. The returned options are set as
+// the flags are parsed, and the caller fills in the rest.
+func addLinkFlags(flags *flag.FlagSet) *reconcilerOptions {
+	opts := &reconcilerOptions{
+		pathCollision:    "merge",
+		skipPathPrefixes: defaultSkipPathPrefixes,
+	}
+	flags.Func("annotation-prefix", fmt.Sprintf("Prefix of the annotations read from ingresses (default %q)", defaultAnnotationPrefix), func(s string) error {
+		if s == "" {
+			return errors.New("must not be empty")
+		}
+		if !strings.HasSuffix(s, "/") {
+			s += "/"
+		}
+		setAnnotationPrefix(s)
+		return nil
+	})
+	flags.Func("extra-links", "YAML or JSON file with a list of static {host, url, text, group} link entries, may be repeated", func(s string) error {
+		links, err := loadExtraLinks(s)
+		opts.extraLinks = append(opts.extraLinks, links...)
+		return err
+	})
+	flags.Func("host-allow", "Glob pattern of hosts to show, showing all hosts if unset - may be repeated", func(s string) error {
+		_, err := path.Match(s, "")
+		opts.hostAllow = append(opts.hostAllow, s)
+		return err
+	})
+	flags.Func("host-deny", "Glob pattern of hosts to hide, taking precedence over --host-allow - may be repeated", func(s string) error {
+		_, err := path.Match(s, "")
+		opts.hostDeny = append(opts.hostDeny, s)
+		return err
+	})
+	flags.StringVar(&opts.stripSuffix, "strip-suffix", "", "Domain suffix to remove from hosts shown without link text, e.g. .example.com to show grafana.example.com as grafana")
+	flags.BoolVar(&opts.normalizeHosts, "normalize-hosts", false, "Treat hosts differing only in case as the same link, sorting them case-insensitively")
+	flags.BoolVar(&opts.requirePaths, "require-paths", false, "Skip hosts that have no paths other than the root, such as redirectors")
+	flags.BoolVar(&opts.onlyReady, "only-ready", false, "Skip ingresses that have not been assigned a load balancer address yet")
+	flags.BoolVar(&opts.showHostless, "show-hostless", false, "List ingresses with rules without a host, or with a default backend that isn't otherwise listed, under their namespace/name without a link")
+	flags.BoolVar(&opts.includeDefaultBackend, "include-default-backend", false, "Also list ingresses with only a default backend and no rules, linking to --default-backend-host or else their load balancer address")
+	flags.StringVar(&opts.defaultBackendHost, "default-backend-host", "", "Host to link ingresses with only a default backend to, if --include-default-backend is set")
+	flags.BoolVar(&opts.collapseWWW, "collapse-www", false, "Merge www. hosts into their apex domain when both are present, keeping the apex link")
+	flags.Func("group-by-label", "Comma-separated label keys to group ingresses without a group annotation by, using the first set, e.g. argocd.argoproj.io/instance,app.kubernetes.io/instance - may be repeated", func(s string) error {
+		for _, key := range strings.Split(s, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				opts.groupByLabel = append(opts.groupByLabel, key)
+			}
+		}
+		return nil
+	})
+	skipPathPrefixesSet := false
+	flags.Func("global-skip-path-prefix", fmt.Sprintf("Path prefix to leave off every host, matched by path element, replacing the defaults - may be repeated, or set to empty to skip none (default %q)", defaultSkipPathPrefixes), func(s string) error {
+		if !skipPathPrefixesSet {
+			opts.skipPathPrefixes, skipPathPrefixesSet = nil, true
+		}
+		if s != "" {
+			if !strings.HasPrefix(s, "/") {
+				return errors.New("must start with /")
+			}
+			opts.skipPathPrefixes = append(opts.skipPathPrefixes, s)
+		}
+		return nil
+	})
+	flags.Func("path-collision", "How to list an Exact and a Prefix path with the same path - one of merge to list both labelled with their type, prefer-exact, prefer-prefix (default merge)", func(s string) error {
+		switch s {
+		case "merge", "prefer-exact", "prefer-prefix":
+			opts.pathCollision = s
+			return nil
+		}
+		return errors.New("must be one of merge, prefer-exact, prefer-prefix")
+	})
+	flags.IntVar(&opts.maxTemplateSize, "max-template-size", 64<<10, "Skip host and path template annotations larger than this many bytes, 0 to disable")
+	flags.BoolVar(&opts.prettifyPathText, "prettify-path-text", false, "Show paths without a path template as capitalised words, such as Grafana for /grafana, rather than the raw path")
+	return opts
+}
+
 func main() {
 	logf.SetLogger(logr.FromSlogHandler(slog.Default().Handler()))
 	log := logf.Log.WithName("ingress-links-controller")
 
+	if len(os.Args) > 1 && os.Args[1] == "validate-template" {
+		os.Exit(validateTemplate(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "list" {
+		os.Exit(listLinks(log, os.Args[2:]))
+	}
+
 	flag.Usage = usage
 
+	flag.Func("log-level", "Minimum level of logs to output, e.g. debug to log why ingresses are skipped", func(s string) error {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(s)); err != nil {
+			return err
+		}
+		slog.SetLogLoggerLevel(level)
+		return nil
+	})
+	reconcilerOpts := addLinkFlags(flag.CommandLine)
 	loadTemplates := flag.String("load-templates", "", "Glob pattern for additional templates files to load")
 	kubeContext := flag.String("context", "", "Context from kubeconfig to use, if not the selected context")
+	preflight := flag.Bool("preflight", true, "List ingresses from the API server at startup, exiting if the controller isn't allowed to")
+	cacheSyncTimeout := flag.Duration("cache-sync-timeout", 2*time.Minute, "Exit if the ingress cache has not synced within this time after starting")
 	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "Timeout for graceful shutdown on INT or TERM signal")
+	pagePath := "/"
+	flag.Func("page-path", "Path to serve the page on, matched exactly if it ends with / - use a trailing {rest...} wildcard to match a prefix (default /)", func(s string) error {
+		if err := checkPagePath(s); err != nil {
+			return err
+		}
+		pagePath = s
+		return nil
+	})
+	defaultRedirect := flag.String("default-redirect", "", "URL to redirect requests for / to, serving the links page at /links instead if --page-path is /")
+	var pinnedLinks []extraLink
+	flag.Func("pinned-links", "YAML or JSON file with a list of static {url, text} links to always show first, in order - may be repeated", func(s string) error {
+		links, err := loadExtraLinks(s)
+		pinnedLinks = append(pinnedLinks, links...)
+		return err
+	})
+	resyncPeriod := flag.Duration("resync-period", 0, fmt.Sprintf("Re-render the page periodically even without ingress changes, at least %s if set", minResyncPeriod))
+	remoteClustersFile := flag.String("remote-clusters", "", "YAML or JSON file listing additional API servers to merge ingresses from, as {name, server, tokenFile, caFile} entries")
+	includeLegacyIngress := flag.Bool("include-legacy-ingress", false, "Also include networking.k8s.io/v1beta1 and extensions/v1beta1 ingresses, if served by the cluster")
+	splitByClass := flag.Bool("split-by-class", false, "Also serve a page for each ingress class at /class/{name}")
+	showNamespace := flag.Bool("show-namespace", false, "Label each link with the namespace of its ingress, using the first namespace by name for hosts declared in several")
+	includeProvenance := flag.Bool("include-provenance", false, "Record the UID and resource version of the ingress declaring each link, as data attributes on the page and link titles in /links.md")
+	var views []string
+	var tabs []pageTab
+	flag.Func("tabs", "Tab to show the output of a template in, as name=template - may be repeated, and the default links are the links template", func(s string) error {
+		name, tpl, found := strings.Cut(s, "=")
+		if !found || name == "" || tpl == "" {
+			return errors.New("must be name=template")
+		}
+		tabs = append(tabs, pageTab{Name: name, Template: tpl})
+		return nil
+	})
+	flag.Func("view", "Name of a template rendering an alternative full page, selected with ?view=name - may be repeated", func(s string) error {
+		views = append(views, s)
+		return nil
+	})
+	groupRel := map[string]string{}
+	flag.Func("group-rel", "Rel attribute for the links in a group, as group=rel, e.g. Partners=nofollow noopener - may be repeated", func(s string) error {
+		group, rel, found := strings.Cut(s, "=")
+		if !found || group == "" {
+			return errors.New("must be group=rel")
+		}
+		groupRel[group] = rel
+		return nil
+	})
+	var groupOrder []string
+	flag.Func("group-order", "Comma-separated group names to show first, in order, before the other groups alphabetically - use / to order nested groups", func(s string) error {
+		for _, name := range strings.Split(s, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				groupOrder = append(groupOrder, name)
+			}
+		}
+		return nil
+	})
+	defaultGroupName := flag.String("default-group-name", "", "Heading for the hosts without a group when there are named groups, such as Other, leaving them without a heading if empty")
+	var defaultGroupLast bool
+	flag.Func("default-group-position", "Where to show the hosts without a group - one of top, bottom (default top)", func(s string) error {
+		switch s {
+		case "top", "bottom":
+			defaultGroupLast = s == "bottom"
+			return nil
+		}
+		return errors.New("must be one of top, bottom")
+	})
+	toc := flag.Bool("toc", false, "Render a table of contents linking to each group at the top of the page")
+	maxLinks := flag.Int("max-links", 0, "Maximum number of hosts to render, dropping the last hosts in sort order")
+	maxPathsPerHost := flag.Int("max-paths-per-host", 0, "Truncate the paths listed for each host, linking to a page with all of them")
+	var qr string
+	flag.Func("qr", "Render a QR code next to the links of all hosts, or only primary hosts - one of all, primary", func(s string) error {
+		if s != "all" && s != "primary" {
+			return errors.New("must be all or primary")
+		}
+		qr = s
+		return nil
+	})
+	// Description URLs are set by whoever can annotate ingresses, and are
+	// fetched from inside the cluster, so they could reach services that are
+	// otherwise internal. Fetches are limited to the --description-host
+	// patterns for that reason.
+	allowDescriptionFetch := flag.Bool("allow-description-fetch", false, "Fetch link descriptions from the URLs in description-url annotations, from hosts matching --description-host")
+	var descriptionHosts []string
+	flag.Func("description-host", "Glob pattern of hosts to fetch descriptions from with --allow-description-fetch, over http or https - may be repeated", func(s string) error {
+		_, err := path.Match(s, "")
+		descriptionHosts = append(descriptionHosts, s)
+		return err
+	})
+	autoFavicon := flag.Bool("auto-favicon", false, "Fetch /favicon.ico from each host and show it next to its link, inlined into the page")
+	descriptionTTL := flag.Duration("description-ttl", time.Hour, "How long to cache fetched descriptions, including failed fetches")
+	htmlMinify := flag.Bool("html-minify", false, "Remove the whitespace between tags from the rendered pages to save bytes")
+	openAll := flag.Bool("open-all", false, "Add a button to each group opening all of its links in new tabs, for which the browser must allow pop-ups from the page")
+	themeToggle := flag.Bool("theme-toggle", false, "Add a button to the page to switch between light and dark themes")
+	httpTimeout := flag.Duration("http-timeout", 10*time.Second, "Timeout for outbound HTTP requests, such as notifications and description fetches")
+	notifyURL := flag.String("notify-url", "", "URL to POST a JSON list of added and removed hosts to when the page changes")
+	tlsCert := flag.String("tls-cert", "", "Certificate file to serve the page over TLS on :443, requires --tls-key")
+	tlsKey := flag.String("tls-key", "", "Private key file for --tls-cert")
+	tlsMinVersion := uint16(tls.VersionTLS12)
+	flag.Func("tls-min-version", "Minimum TLS version to serve the page with - one of 1.2, 1.3 (default 1.2)", func(s string) error {
+		switch s {
+		case "1.2":
+			tlsMinVersion = tls.VersionTLS12
+		case "1.3":
+			tlsMinVersion = tls.VersionTLS13
+		default:
+			return errors.New("must be 1.2 or 1.3")
+		}
+		return nil
+	})
+	var tlsCiphers []uint16
+	flag.Func("tls-ciphers", "Comma-separated TLS 1.2 cipher suites to allow, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, defaulting to Go's secure suites - TLS 1.3 suites are not configurable", func(s string) error {
+		tlsCiphers = nil
+		for _, name := range strings.Split(s, ",") {
+			id, err := parseCipherSuite(strings.TrimSpace(name))
+			if err != nil {
+				return err
+			}
+			tlsCiphers = append(tlsCiphers, id)
+		}
+		return nil
+	})
+	clientCA := flag.String("client-ca", "", "CA certificate file to require and verify client certificates against, requires --tls-cert")
+	maxRenderFailures := flag.Int("max-render-failures", 3, "Report not ready after this many consecutive failed page renders, 0 to disable")
+	cacheStaleThreshold := flag.Duration("cache-stale-threshold", 5*time.Minute, "Fail the liveness check if the cached ingresses differ from the API server for longer than this, 0 to disable")
+	var extraHead string
+	flag.Func("extra-head", "Trusted HTML to add to the head of the page, such as meta tags, or @file to read it from a file", func(s string) error {
+		if path, ok := strings.CutPrefix(s, "@"); ok {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			s = string(data)
+		}
+		extraHead = strings.TrimSpace(s)
+		return nil
+	})
+	var embedFrameAncestors []string
+	flag.Func("embed-frame-ancestors", "Comma-separated CSP sources allowed to embed the page body served at /embed in a frame, e.g. 'self',https://portal.example.com - may be repeated", func(s string) error {
+		for _, source := range strings.Split(s, ",") {
+			if source = strings.TrimSpace(source); source != "" {
+				embedFrameAncestors = append(embedFrameAncestors, source)
+			}
+		}
+		return nil
+	})
+	sitemap := flag.Bool("sitemap", false, "Serve the URLs of all links at /sitemap.xml for crawlers of internal search engines")
+	robotsTxt := flag.String("robots-txt", "", "File to serve at /robots.txt instead of disallowing all crawlers")
+	instanceName := flag.String("instance-name", "", "Name of this controller instance in the feed at /feed.atom, defaulting to the hostname")
+	enableWebhook := flag.Bool("enable-webhook", false, "Serve a validating admission webhook at "+validateIngressPath+" rejecting ingresses with template annotations that fail to parse")
+	webhookPort := flag.Int("webhook-port", 9443, "Port to serve the admission webhook on")
+	webhookCertDir := flag.String("webhook-cert-dir", "", "Directory containing the webhook's tls.crt and tls.key (default /tmp/k8s-webhook-server/serving-certs)")
+	var configMap types.NamespacedName
+	flag.Func("config-map", "Config map to pass to the page templates as .Options.Config, as namespace/name, re-rendering the page when it changes", func(s string) error {
+		namespace, name, ok := strings.Cut(s, "/")
+		if !ok || namespace == "" || name == "" {
+			return errors.New("must be namespace/name")
+		}
+		configMap = types.NamespacedName{Namespace: namespace, Name: name}
+		return nil
+	})
+	debug := flag.Bool("debug", false, "Serve debugging endpoints, such as POST /debug/reconcile?namespace=x&name=y to re-render the page from the API server")
+	unifiedPort := flag.Bool("unified-port", false, "Serve /metrics, /alive and /ready on the page's port instead of on ports 8080 and 8081")
+	requireForwardedUser := flag.Bool("require-forwarded-user", false, "Reject page requests without an X-Forwarded-User header set by an auth proxy")
 	flag.Func("template", "Alternative templates - use name=tpl to create/replace a non-root template", func(s string) error {
 		name, text, found := strings.Cut(s, "=")
 		if !found || strings.ContainsAny(name, `<>{}'"&`) || strings.ContainsFunc(name, unicode.IsSpace) || strings.ContainsFunc(name, unicode.IsControl) {
@@ -124,6 +1083,36 @@ func main() {
 		}
 	}
 
+	if *allowDescriptionFetch && len(descriptionHosts) == 0 {
+		log.Error(nil, "--allow-description-fetch requires at least one --description-host to fetch from")
+		os.Exit(1)
+	}
+
+	if *resyncPeriod > 0 && *resyncPeriod < minResyncPeriod {
+		log.Info("Raising resync period to minimum", "requested", *resyncPeriod, "minimum", minResyncPeriod)
+		*resyncPeriod = minResyncPeriod
+	}
+
+	for _, view := range views {
+		if srvTpl.Lookup(view) == nil {
+			log.Error(nil, "Template for view not found", "view", view)
+			os.Exit(1)
+		}
+	}
+	for _, tab := range tabs {
+		if srvTpl.Lookup(tab.Template) == nil {
+			log.Error(nil, "Template for tab not found", "tab", tab.Name, "template", tab.Template)
+			os.Exit(1)
+		}
+	}
+
+	log.Info("Effective configuration",
+		"version", buildVersion(),
+		"flags", flagValues(flag.CommandLine, os.Args[1:]),
+		"annotationPrefix", annotationPrefix,
+		"templates", templateNames(srvTpl),
+	)
+
 	baseTpl, err := srvTpl.Clone()
 	if err != nil {
 		log.Error(err, "Failed to clone templates")
@@ -136,121 +1125,673 @@ func main() {
 		os.Exit(1)
 	}
 
+	metricsAddr, healthProbeAddr := ":8080", ":8081"
+	if *unifiedPort {
+		// "0" disables the manager's servers.
+		metricsAddr, healthProbeAddr = "0", "0"
+	}
+	// Only the --config-map is cached, rather than every config map in the
+	// cluster.
+	var cacheOpts cache.Options
+	if configMap.Name != "" {
+		cacheOpts.ByObject = map[client.Object]cache.ByObject{&corev1.ConfigMap{}: {
+			Namespaces: map[string]cache.Config{configMap.Namespace: {
+				FieldSelector: fields.OneTermEqualSelector("metadata.name", configMap.Name),
+			}},
+		}}
+	}
 	m, err := manager.New(kubeConf, manager.Options{
-		Metrics:                server.Options{BindAddress: ":8080"},
-		HealthProbeBindAddress: ":8081",
+		Metrics:                server.Options{BindAddress: metricsAddr},
+		HealthProbeBindAddress: healthProbeAddr,
 		LivenessEndpointName:   "/alive",
 		ReadinessEndpointName:  "/ready",
+		Controller:             ctrlconfig.Controller{CacheSyncTimeout: *cacheSyncTimeout},
+		WebhookServer:          webhook.NewServer(webhook.Options{Port: *webhookPort, CertDir: *webhookCertDir}),
+		Cache:                  cacheOpts,
 	})
 	if err != nil {
 		log.Error(err, "Failed to create manager")
 		os.Exit(1)
 	}
 
-	var pagePtr atomic.Pointer[string]
+	var pagePtr atomic.Pointer[renderSnapshot]
 
-	_ = m.AddHealthzCheck("ping", healthz.Ping)
-	_ = m.AddReadyzCheck("have-page", func(req *http.Request) error {
+	// The checks are served by the manager, or by the page server if
+	// --unified-port is set.
+	healthChecks := map[string]healthz.Checker{"ping": healthz.Ping}
+	if *cacheStaleThreshold > 0 {
+		checker := newCacheChecker(log, m.GetClient(), m.GetAPIReader(), *cacheStaleThreshold)
+		_ = m.Add(checker)
+		healthChecks["cache"] = checker.Check
+	}
+	readyChecks := map[string]healthz.Checker{"have-page": func(req *http.Request) error {
 		if pagePtr.Load() == nil {
 			return errors.New("page not rendered")
 		}
 		return nil
-	})
+	}}
+	var renderFailures atomic.Int32
+	if *maxRenderFailures > 0 {
+		readyChecks["rendering"] = func(req *http.Request) error {
+			if failures := int(renderFailures.Load()); failures >= *maxRenderFailures {
+				return fmt.Errorf("%d consecutive page renders failed", failures)
+			}
+			return nil
+		}
+	}
+	if !*unifiedPort {
+		for name, check := range healthChecks {
+			_ = m.AddHealthzCheck(name, check)
+		}
+		for name, check := range readyChecks {
+			_ = m.AddReadyzCheck(name, check)
+		}
+	}
+
+	pageOpts := pageOptions{
+		ThemeToggle:      *themeToggle,
+		QR:               qr != "",
+		Descriptions:     *allowDescriptionFetch,
+		ShowNamespace:    *showNamespace,
+		TOC:              *toc,
+		Favicons:         *autoFavicon,
+		Pinned:           pinnedLinks,
+		ExtraHead:        template.HTML(extraHead),
+		GroupOrder:       groupOrder,
+		DefaultGroupName: *defaultGroupName,
+		DefaultGroupLast: defaultGroupLast,
+		Tabs:             tabs,
+		MaxPathsPerHost:  *maxPathsPerHost,
+		HostDetailPrefix: hostDetailPrefix(servedPagePath(pagePath, *defaultRedirect)),
+		MaxLinks:         *maxLinks,
+		Minify:           *htmlMinify,
+		OpenAll:          *openAll,
+	}
+
+	reconcilerOpts.splitByClass = *splitByClass
+	reconcilerOpts.showNamespace = *showNamespace
+	reconcilerOpts.groupRel = groupRel
+	reconcilerOpts.qr = qr
+	reconcilerOpts.page = pageOpts
+	reconcilerOpts.views = views
+	reconcilerOpts.resyncPeriod = *resyncPeriod
+	reconcilerOpts.renderFailures = &renderFailures
+	reconcilerOpts.configMap = configMap
+	reconcilerOpts.includeProvenance = *includeProvenance
+	if *instanceName == "" {
+		if *instanceName, err = os.Hostname(); err != nil {
+			log.Error(err, "Failed to get hostname for the instance name")
+			os.Exit(1)
+		}
+	}
+	reconcilerOpts.feed = newLinkFeed(*instanceName)
 
-	if err = builder.ControllerManagedBy(m).For(&netv1.Ingress{}).Complete(buildReconciler(log, m.GetClient(), &pagePtr, baseTpl)); err != nil {
+	httpClient := newHTTPClient(*httpTimeout)
+	if *allowDescriptionFetch {
+		reconcilerOpts.descriptions = newDescriptionFetcher(log, httpClient, *descriptionTTL, descriptionHosts)
+	}
+	if *autoFavicon {
+		reconcilerOpts.favicons = newFaviconFetcher(log, httpClient, faviconTTL)
+	}
+	if *notifyURL != "" {
+		reconcilerOpts.notifier = newNotifier(log, httpClient, *notifyURL)
+		_ = m.Add(reconcilerOpts.notifier)
+	}
+
+	if *includeLegacyIngress {
+		if reconcilerOpts.legacyIngressVersions, err = servedLegacyIngressVersions(m.GetRESTMapper()); err != nil {
+			log.Error(err, "Failed to discover legacy ingress versions")
+			os.Exit(1)
+		}
+		log.Info("Including legacy ingresses", "versions", reconcilerOpts.legacyIngressVersions)
+	}
+
+	ctrlBuilder := builder.ControllerManagedBy(m).Named("ingress").Watches(&netv1.Ingress{}, renderAll)
+	if *remoteClustersFile != "" {
+		configs, err := loadRemoteClusters(*remoteClustersFile)
+		if err != nil {
+			log.Error(err, "Failed to load remote clusters")
+			os.Exit(1)
+		}
+		for _, config := range configs {
+			remote, err := newRemoteCluster(config)
+			if err != nil {
+				log.Error(err, "Failed to set up remote cluster", "cluster", config.Name)
+				os.Exit(1)
+			}
+			// Each remote cluster has its own cache, started and stopped
+			// with the manager. Added as a cluster, the manager would wait
+			// for its cache to sync before starting the controller.
+			_ = m.Add(manager.RunnableFunc(remote.Start))
+			ctrlBuilder = ctrlBuilder.WatchesRawSource(unsyncedSource{source.Kind[client.Object](remote.GetCache(), &netv1.Ingress{}, renderAll)})
+			reconcilerOpts.remoteClusters = append(reconcilerOpts.remoteClusters, remoteCluster{name: config.Name, cache: remote.GetCache()})
+		}
+		log.Info("Including remote clusters", "clusters", len(reconcilerOpts.remoteClusters))
+	}
+	// Fetches complete after the reconcile that started them, and render
+	// again with the values they fetched.
+	for _, fetcher := range []*cachedFetcher{reconcilerOpts.descriptions, reconcilerOpts.favicons} {
+		if fetcher != nil {
+			ctrlBuilder = ctrlBuilder.WatchesRawSource(source.Channel(fetcher.Changed(), renderAll))
+		}
+	}
+	if configMap.Name != "" {
+		// The cache only holds the one config map, so any event is for it.
+		ctrlBuilder = ctrlBuilder.Watches(&corev1.ConfigMap{}, renderAll)
+	}
+	for _, gv := range reconcilerOpts.legacyIngressVersions {
+		ctrlBuilder = ctrlBuilder.Watches(legacyIngressObject(gv), renderAll)
+	}
+	if *debug {
+		reconcilerOpts.liveReader = m.GetAPIReader()
+	}
+	reconciler := buildReconciler(log, m.GetClient(), &pagePtr, baseTpl, *reconcilerOpts)
+	if err = ctrlBuilder.Complete(reconciler); err != nil {
 		log.Error(err, "Failed to create controller")
 	}
+	var debugReconcile func(context.Context) error
+	if *debug {
+		debugReconcile = func(ctx context.Context) error {
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: liveReconcileName}})
+			return err
+		}
+	}
+
+	// The webhook server only starts once it is retrieved from the manager.
+	if *enableWebhook {
+		m.GetWebhookServer().Register(validateIngressPath, &webhook.Admission{
+			Handler: ingressValidator(baseTpl, admission.NewDecoder(m.GetScheme())),
+		})
+	}
+
+	tlsConfig, err := buildTLSConfig(*tlsCert, *tlsKey, *clientCA, tlsMinVersion, tlsCiphers)
+	if err != nil {
+		log.Error(err, "Failed to configure TLS")
+		os.Exit(1)
+	}
+
+	var robots []byte
+	if *robotsTxt != "" {
+		if robots, err = os.ReadFile(*robotsTxt); err != nil {
+			log.Error(err, "Failed to read robots.txt", "path", *robotsTxt)
+			os.Exit(1)
+		}
+	}
+
+	srv := buildServer(log, &pagePtr, serverOptions{
+		page:                 pageOpts,
+		pagePath:             pagePath,
+		defaultRedirect:      *defaultRedirect,
+		requireForwardedUser: *requireForwardedUser,
+		tlsConfig:            tlsConfig,
+		robotsTxt:            robots,
+		feed:                 reconcilerOpts.feed,
+		sitemap:              *sitemap,
+		embedFrameAncestors:  embedFrameAncestors,
+		unifiedPort:          *unifiedPort,
+		healthChecks:         healthChecks,
+		readyChecks:          readyChecks,
+		debugReconcile:       debugReconcile,
+		debugReader:          m.GetAPIReader(),
+	})
+	// The manager's server only serves plain HTTP, so TLS is handled by
+	// wrapping the listener.
+	var listener net.Listener
+	if srv.TLSConfig != nil {
+		if listener, err = net.Listen("tcp", srv.Addr); err != nil {
+			log.Error(err, "Failed to listen", "addr", srv.Addr)
+			os.Exit(1)
+		}
+		listener = tls.NewListener(listener, srv.TLSConfig)
+	}
 
 	_ = m.Add(&manager.Server{
 		Name:            "main",
-		Server:          buildServer(log, &pagePtr),
+		Server:          srv,
+		Listener:        listener,
 		ShutdownTimeout: shutdownTimeout,
 	})
 
-	if err := m.Start(signals.SetupSignalHandler()); !errors.Is(err, context.Canceled) {
+	ctx := signals.SetupSignalHandler()
+	// Without permission to list ingresses, the cache never syncs and the
+	// controller stays unready with only reconcile errors to go by, so this
+	// is checked up front. Other errors may be transient and are left to the
+	// manager to retry.
+	if *preflight {
+		if err := m.GetAPIReader().List(ctx, &netv1.IngressList{}, client.Limit(1)); apierrors.IsForbidden(err) {
+			log.Error(err, "Not allowed to list ingresses, grant the controller's service account list and watch on ingresses in all namespaces", "apiGroup", netv1.GroupName, "resource", "ingresses", "verbs", []string{"list", "watch"})
+			os.Exit(1)
+		} else if err != nil {
+			log.Error(err, "Preflight list of ingresses failed, starting anyway")
+		}
+	}
+	go logCacheSync(ctx, log, m.GetCache(), *cacheSyncTimeout)
+	if err := m.Start(ctx); !errors.Is(err, context.Canceled) {
 		log.Error(err, "Manager failed")
 		os.Exit(1)
 	}
 }
 
-func buildReconciler(log logr.Logger, kubeClient client.Client, pagePtr *atomic.Pointer[string], tpl *template.Template) reconcile.TypedReconciler[reconcile.Request] {
+// renderAll maps events for any object to the same request, as every reconcile
+// renders all ingresses. The workqueue then coalesces bursts of events, such as
+// an informer resync, into a single render.
+var renderAll = handler.EnqueueRequestsFromMapFunc(func(context.Context, client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "links"}}}
+})
+
+func buildReconciler(log logr.Logger, kubeClient client.Client, pagePtr *atomic.Pointer[renderSnapshot], tpl *template.Template, opts reconcilerOptions) reconcile.TypedReconciler[reconcile.Request] {
+	// qrCodes caches the QR code of each URL between reconciles. The
+	// controller doesn't run reconciles concurrently, but the debug endpoint
+	// runs them outside the controller, so they are serialised by mu.
+	var mu sync.Mutex
+	qrCodes := map[string]template.HTML{}
+	groupTemplates := &groupTemplateCache{}
+	remoteIngresses := map[string][]netv1.Ingress{}
+	var generation uint64
 	return reconcile.Func(func(ctx context.Context, r reconcile.Request) (reconcile.Result, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var reader client.Reader = kubeClient
+		if r.Name == liveReconcileName && opts.liveReader != nil {
+			reader = opts.liveReader
+		}
 		is := &netv1.IngressList{}
-		if err := kubeClient.List(ctx, is); err != nil {
+		if err := reader.List(ctx, is); err != nil {
 			return reconcile.Result{}, err
 		}
 
-		hosts := map[string]*hostValues{}
-		var err error
+		// Clusters serving several versions return the same ingresses from
+		// each, so only add legacy ingresses not already seen.
+		seen := map[types.UID]bool{}
 		for _, item := range is.Items {
-			if item.Annotations[skipAnnotation] == "true" {
-				continue
+			seen[item.UID] = true
+		}
+		for _, gv := range opts.legacyIngressVersions {
+			items, err := listLegacyIngresses(ctx, reader, gv)
+			if err != nil {
+				return reconcile.Result{}, fmt.Errorf("failed to list %s ingresses: %w", gv, err)
 			}
-
+			for _, item := range items {
+				if !seen[item.UID] {
+					seen[item.UID] = true
+					is.Items = append(is.Items, item)
+				}
+			}
+		}
+
+		// Ingresses of remote clusters are tagged with the cluster they came
+		// from, and merged with the local ones by host. A remote cluster that
+		// can't be listed keeps the ingresses it last listed, or has none if
+		// it never synced, rather than failing the render.
+		sources := map[types.UID]string{}
+		for _, remote := range opts.remoteClusters {
+			items, err := remote.listIngresses(ctx)
+			if err != nil {
+				log.Error(err, "Failed to list ingresses of remote cluster, keeping its last listed ingresses", "cluster", remote.name)
+				remoteListFailures.WithLabelValues(remote.name).Inc()
+				items = remoteIngresses[remote.name]
+			} else {
+				remoteIngresses[remote.name] = items
+			}
+			for _, item := range items {
+				if !seen[item.UID] {
+					seen[item.UID] = true
+					sources[item.UID] = remote.name
+					is.Items = append(is.Items, item)
+				}
+			}
+		}
+
+		// The first ingress declaring a host determines most of its values, so
+		// process ingresses in a fixed order rather than the order listed.
+		slices.SortFunc(is.Items, func(a, b netv1.Ingress) int {
+			return cmp.Or(strings.Compare(a.Namespace, b.Namespace), strings.Compare(a.Name, b.Name), strings.Compare(sources[a.UID], sources[b.UID]))
+		})
+
+		hosts := map[string]*hostValues{}
+		restricted := false
+		// requeueAfter is shortened so that links disappear when they expire.
+		requeueAfter := opts.resyncPeriod
+		now := time.Now()
+		for _, item := range is.Items {
+			itemLog := log.WithValues("namespace", item.Namespace, "name", item.Name)
+			source := sources[item.UID]
+			if source != "" {
+				itemLog = itemLog.WithValues("source", source)
+			}
+			if item.Annotations[skipAnnotation] == "true" {
+				itemLog.V(1).Info("Skipping ingress", "reason", "skip annotation")
+				continue
+			}
+			if opts.onlyReady && len(item.Status.LoadBalancer.Ingress) == 0 {
+				itemLog.V(1).Info("Skipping ingress", "reason", "no load balancer address")
+				continue
+			}
+			if value := item.Annotations[expiresAnnotation]; value != "" {
+				expires, err := time.Parse(time.RFC3339, value)
+				switch {
+				case err != nil:
+					itemLog.Error(err, "Ignoring invalid expires annotation", "annotation", expiresAnnotation)
+				case !now.Before(expires):
+					itemLog.Info("Skipping expired ingress", "expires", expires)
+					continue
+				case requeueAfter == 0 || expires.Sub(now) < requeueAfter:
+					requeueAfter = expires.Sub(now)
+				}
+			}
+
+			allowedGroups := parseAllowedGroups(item.Annotations[allowedGroupsAnnotation])
+			restricted = restricted || allowedGroups != nil
+
+			port, err := parsePort(item.Annotations[portAnnotation])
+			if err != nil {
+				itemLog.Error(err, "Ignoring invalid port annotation", "annotation", portAnnotation)
+			}
+
+			insecure := item.Annotations[insecureAnnotation] == "true"
+			schemes, err := parseSchemes(item.Annotations[schemesAnnotation])
+			if err != nil {
+				itemLog.Error(err, "Ignoring invalid schemes annotation", "annotation", schemesAnnotation)
+			}
+			if len(schemes) > 0 {
+				insecure = schemes[0] == "http"
+			}
+
+			group := item.Annotations[groupAnnotation]
+			if strings.Contains(group, "{{") {
+				group = groupTemplates.execute(itemLog, group, &item)
+			}
+			for _, key := range opts.groupByLabel {
+				if group != "" {
+					break
+				}
+				group = item.Labels[key]
+			}
+
+			var order int
+			if value := item.Annotations[orderAnnotation]; value != "" {
+				if order, err = strconv.Atoi(value); err != nil {
+					itemLog.Error(err, "Ignoring invalid order annotation", "annotation", orderAnnotation)
+				}
+			}
+
+			pin := item.Annotations[pinAnnotation]
+			if pin != "" && pin != "top" && pin != "bottom" {
+				itemLog.Error(nil, "Ignoring invalid pin annotation, must be top or bottom", "annotation", pinAnnotation, "value", pin)
+				pin = ""
+			}
+
 			var hostTpl *template.Template
-			if template := item.Annotations[hostTemplateAnnotation]; template != "" {
-				hostTpl, err = tpl.Clone()
-				if err != nil {
-					return reconcile.Result{}, err
+			if name := item.Annotations[hostTemplateNameAnnotation]; name != "" && tpl.Lookup(name) == nil {
+				itemLog.Info("Named host template not found, falling back", "annotation", hostTemplateNameAnnotation, "template", name)
+			} else if name != "" {
+				// Executing a template prevents further clones, so execute a
+				// clone rather than the shared template. Failing to set up one
+				// ingress's template only falls back to the default text.
+				if hostTpl, err = tpl.Clone(); err != nil {
+					itemLog.Error(err, "Failed to clone named host template, falling back", "annotation", hostTemplateNameAnnotation, "template", name)
+					templateErrors.WithLabelValues(item.Namespace, item.Name, "host").Inc()
+					hostTpl = nil
+				} else {
+					hostTpl = hostTpl.Lookup(name)
 				}
-				if _, err = hostTpl.Parse(template); err != nil {
+			}
+			if template := item.Annotations[hostTemplateAnnotation]; hostTpl == nil && template != "" && !templateTooLarge(itemLog, &item, "host", hostTemplateAnnotation, template, opts.maxTemplateSize) {
+				if hostTpl, err = parseAnnotationTemplate(tpl, template); err != nil {
 					log.Error(err, "Failed to parse host template from %s annotation for ingress %s/%s", hostTemplateAnnotation, item.Namespace, item.Name)
+					templateErrors.WithLabelValues(item.Namespace, item.Name, "host").Inc()
 					hostTpl = nil
 				}
 			}
 
 			var pathTpl *template.Template
-			if template := item.Annotations[pathTemplateAnnotation]; template != "" {
-				pathTpl, err = tpl.Clone()
-				if err != nil {
-					return reconcile.Result{}, err
-				}
-				if _, err = pathTpl.Parse(template); err != nil {
+			if template := item.Annotations[pathTemplateAnnotation]; template != "" && !templateTooLarge(itemLog, &item, "path", pathTemplateAnnotation, template, opts.maxTemplateSize) {
+				if pathTpl, err = parseAnnotationTemplate(tpl, template); err != nil {
 					log.Error(err, "Failed to parse path template from %s annotation for ingress %s/%s", pathTemplateAnnotation, item.Namespace, item.Name)
+					templateErrors.WithLabelValues(item.Namespace, item.Name, "path").Inc()
 					pathTpl = nil
 				}
 			}
 
+			// Entries for ingresses without a host are keyed by ingress, as
+			// remote clusters may have ingresses of the same name.
+			itemKey := path.Join(source, item.Namespace, item.Name)
+			hostlessKey := "hostless/" + itemKey
+			if len(item.Spec.Rules) == 0 && item.Spec.DefaultBackend != nil {
+				var host string
+				if opts.includeDefaultBackend {
+					host = cmp.Or(opts.defaultBackendHost, loadBalancerAddress(&item))
+				}
+				switch {
+				case host == "" && opts.showHostless:
+					hosts[hostlessKey] = newHostlessValues(&item, group, allowedGroups)
+					hosts[hostlessKey].Source = source
+					if opts.includeProvenance {
+						setProvenance(hosts[hostlessKey], &item)
+					}
+					continue
+				case host == "" && opts.includeDefaultBackend:
+					itemLog.V(1).Info("Skipping default backend", "reason", "no load balancer address")
+					continue
+				case host == "":
+					itemLog.V(1).Info("Skipping ingress", "reason", "only a default backend")
+					continue
+				}
+				// Several ingresses may link to the same host, so they are
+				// keyed by ingress rather than host.
+				hosts["default-backend/"+itemKey] = &hostValues{
+					Host:           host,
+					Namespace:      item.Namespace,
+					Source:         source,
+					Class:          ingressClass(&item),
+					CreatedAt:      item.CreationTimestamp.Time,
+					Port:           port,
+					Insecure:       insecure,
+					URL:            hostURL(host, port, insecure),
+					Text:           template.HTML(template.HTMLEscapeString(item.Name)),
+					Tooltip:        cmp.Or(item.Annotations[tooltipAnnotation], describeBackend(item.Spec.DefaultBackend)),
+					Paths:          map[string]*pathValues{},
+					Confirm:        item.Annotations[confirmAnnotation],
+					AllowedGroups:  allowedGroups,
+					DefaultBackend: describeBackend(item.Spec.DefaultBackend),
+				}
+				if opts.includeProvenance {
+					setProvenance(hosts["default-backend/"+itemKey], &item)
+				}
+				continue
+			}
+
 			for _, rule := range item.Spec.Rules {
 				host := rule.Host
+				if host == "" && opts.showHostless {
+					if hosts[hostlessKey] == nil {
+						hosts[hostlessKey] = newHostlessValues(&item, group, allowedGroups)
+						hosts[hostlessKey].Source = source
+						if opts.includeProvenance {
+							setProvenance(hosts[hostlessKey], &item)
+						}
+					}
+					continue
+				}
 				if host == "" {
+					itemLog.V(1).Info("Skipping rule", "reason", "no host")
+					continue
+				}
+				if !hostAllowed(host, opts.hostAllow, opts.hostDeny) {
+					itemLog.V(1).Info("Skipping rule", "host", host, "reason", "host filtered")
 					continue
 				}
 
-				if hosts[host] == nil {
-					hosts[host] = &hostValues{
-						Host:  host,
-						Paths: map[string]*pathValues{},
+				// Host names are case-insensitive, so optionally merge hosts
+				// differing only in case, displaying the first seen.
+				key := host
+				if opts.normalizeHosts {
+					key = strings.ToLower(host)
+				}
+				if hosts[key] == nil {
+					hosts[key] = &hostValues{
+						Host:          host,
+						Namespace:     item.Namespace,
+						Source:        source,
+						Class:         ingressClass(&item),
+						Port:          port,
+						Insecure:      insecure,
+						Schemes:       schemes,
+						URL:           hostURL(host, port, insecure),
+						Group:         group,
+						Paths:         map[string]*pathValues{},
+						Confirm:       item.Annotations[confirmAnnotation],
+						AllowedGroups: allowedGroups,
+					}
+					if opts.includeProvenance {
+						setProvenance(hosts[key], &item)
+					}
+				} else {
+					hosts[key].AllowedGroups = mergeAllowedGroups(hosts[key].AllowedGroups, allowedGroups)
+				}
+				hv := hosts[key]
+				if created := item.CreationTimestamp.Time; hv.CreatedAt.IsZero() || created.Before(hv.CreatedAt) {
+					hv.CreatedAt = created
+				}
+				if opts.showNamespace {
+					hv.NamespaceLabel = hv.Namespace
+				}
+				if hv.Group == "" {
+					hv.Group = group
+				}
+				if hv.SortKey == "" {
+					hv.SortKey = item.Annotations[sortKeyAnnotation]
+				}
+				if hv.Tooltip == "" {
+					hv.Tooltip = item.Annotations[tooltipAnnotation]
+				}
+				if hv.Order == 0 {
+					hv.Order = order
+				}
+				if hv.Pin == "" {
+					hv.Pin = pin
+				}
+				if item.Annotations[primaryAnnotation] == "true" {
+					hv.Primary = true
+				}
+				if item.Annotations[compactAnnotation] == "true" {
+					hv.Compact = true
+				}
+				for name, value := range dataAttributes(itemLog, item.Annotations) {
+					if hv.Data == nil {
+						hv.Data = map[string]string{}
+					}
+					if _, exists := hv.Data[name]; !exists {
+						hv.Data[name] = value
+					}
+				}
+				if item.Annotations[collapsePathsAnnotation] == "true" {
+					hv.Collapse = true
+				}
+				extraURLs, err := parseExtraURLs(item.Annotations[extraURLAnnotation])
+				if err != nil {
+					itemLog.Error(err, "Ignoring invalid extra URL annotation", "annotation", extraURLAnnotation)
+				}
+				for _, u := range extraURLs {
+					if !slices.Contains(hv.ExtraURLs, u) {
+						hv.ExtraURLs = append(hv.ExtraURLs, u)
 					}
 				}
-				hv := hosts[host]
+				if value := item.Annotations[descriptionURLAnnotation]; value != "" && hv.Description == "" && opts.descriptions != nil {
+					hv.Description = opts.descriptions.Get(ctx, value)
+				}
 
-				if hostTpl != nil {
+				// Like the other host values, the text comes from the first
+				// ingress that sets it, while paths are combined from all
+				// ingresses declaring the host. A host repeated in the rules
+				// of one ingress likewise takes its text from the first rule.
+				if hostTpl != nil && hv.Text == "" {
 					var sb strings.Builder
 					if err := hostTpl.Execute(&sb, hostTemplateValue{
-						Host:    host,
-						Ingress: &item,
-						Rule:    &rule,
+						Host:     host,
+						Ingress:  &item,
+						Rule:     &rule,
+						TLSHosts: tlsHosts(&item, host),
 					}); err != nil {
 						log.Error(err, "Failed to execute host template for ingress %s/%s")
+						templateErrors.WithLabelValues(item.Namespace, item.Name, "host").Inc()
 					} else {
 						hv.Text = template.HTML(sb.String())
 					}
 				}
 
+				if rule.HTTP == nil {
+					itemLog.V(1).Info("Skipping paths", "host", host, "reason", "no HTTP rule")
+					continue
+				}
+
 				for _, path := range rule.HTTP.Paths {
 					pv := pathValues{
-						Host: host,
+						Host:          host,
+						Namespace:     item.Namespace,
+						Port:          port,
+						Confirm:       item.Annotations[confirmAnnotation],
+						Heading:       item.Annotations[headingAnnotation],
+						Tooltip:       item.Annotations[tooltipAnnotation],
+						AllowedGroups: allowedGroups,
 					}
+					var skipReason string
 					switch {
 					case path.PathType == nil:
-					case *path.PathType == netv1.PathTypeExact:
-						pv.Path = path.Path
-					case *path.PathType == netv1.PathTypePrefix:
+						skipReason = "nil-type"
+					case *path.PathType == netv1.PathTypeImplementationSpecific:
+						skipReason = "implementation-specific"
+					case *path.PathType != netv1.PathTypeExact && *path.PathType != netv1.PathTypePrefix:
+						skipReason = "unsupported-type"
+					case path.Path == "":
+						skipReason = "empty"
+					case hasPathPrefix(path.Path, opts.skipPathPrefixes):
+						skipReason = "skipped-prefix"
+					default:
 						pv.Path = path.Path
+						pv.PathType = string(*path.PathType)
 					}
 
-					if pv.Path == "" || hv.Paths[pv.Path] != nil {
+					if skipReason != "" {
+						skippedPaths.WithLabelValues(skipReason).Inc()
+						itemLog.V(1).Info("Skipping path", "host", host, "path", path.Path, "reason", skipReason)
+						continue
+					}
+					if pv.Path == "/" {
+						// The root path is kept for the host, but isn't listed
+						// as a link of its own.
+						skippedPaths.WithLabelValues("root").Inc()
+						itemLog.V(1).Info("Not listing path", "host", host, "path", path.Path, "reason", "root")
+					}
+					pv.URL = hostURL(host, port, hv.Insecure) + pv.Path
+					if opts.showNamespace && pv.Namespace != hv.Namespace {
+						pv.NamespaceLabel = pv.Namespace
+					}
+					// An Exact and a Prefix path with the same path collide.
+					// They are listed separately or one replaces the other
+					// depending on --path-collision, while other duplicates
+					// take their text from the first. The root path isn't
+					// listed, so only needs the one entry.
+					pathKey, labelType := pv.Path, false
+					if existing := hv.Paths[pathKey]; existing != nil && existing.PathType != pv.PathType && pv.Path != "/" {
+						switch opts.pathCollision {
+						case "prefer-exact", "prefer-prefix":
+							if isExact := pv.PathType == string(netv1.PathTypeExact); isExact != (opts.pathCollision == "prefer-exact") {
+								existing.AllowedGroups = mergeAllowedGroups(existing.AllowedGroups, allowedGroups)
+								continue
+							}
+							pv.AllowedGroups = mergeAllowedGroups(existing.AllowedGroups, allowedGroups)
+							delete(hv.Paths, pathKey)
+						default:
+							// The type that comes second is kept under a key
+							// sorting right after the path.
+							labelPathType(existing, opts.prettifyPathText)
+							pathKey, labelType = pv.Path+" "+pv.PathType, true
+						}
+					}
+					if existing := hv.Paths[pathKey]; existing != nil {
+						existing.AllowedGroups = mergeAllowedGroups(existing.AllowedGroups, allowedGroups)
 						continue
 					}
 
@@ -262,80 +1803,1544 @@ func buildReconciler(log logr.Logger, kubeClient client.Client, pagePtr *atomic.
 							Rule:    &rule,
 						}); err != nil {
 							log.Error(err, "Failed to execute host template for ingress %s/%s")
+							templateErrors.WithLabelValues(item.Namespace, item.Name, "path").Inc()
 						} else {
 							pv.Text = template.HTML(sb.String())
 						}
 					}
+					if pv.Text == "" {
+						pv.defaultText = true
+						if opts.prettifyPathText {
+							pv.Text = template.HTML(template.HTMLEscapeString(prettyPathText(pv.Path)))
+						}
+					}
+					if labelType {
+						labelPathType(&pv, opts.prettifyPathText)
+					}
 
-					hosts[host].Paths[pv.Path] = &pv
+					hv.Paths[pathKey] = &pv
 				}
 			}
 		}
 
-		// Sort by each segment of the domains starting from the TLD, i.e. the
-		// last segment. Meaning: Subdomains of the same domain are grouped
-		// together, and subdomains come after their parent domain if present.
-		hostsList := slices.Collect(maps.Values(hosts))
-		sort.Slice(hostsList, func(i, j int) bool {
-			isegs, jsegs := strings.Split(hostsList[i].Host, "."), strings.Split(hostsList[j].Host, ".")
-			for ridx := 0; ridx < len(isegs) && ridx < len(jsegs); ridx++ {
-				iseg, jseg := isegs[len(isegs)-ridx-1], jsegs[len(jsegs)-ridx-1]
-				if cmp := strings.Compare(iseg, jseg); cmp != 0 {
-					return cmp < 0
+		// Hosts are only known to have no listed paths once all ingresses
+		// declaring them have been processed. Static entries have no paths,
+		// so are added afterwards.
+		if opts.requirePaths {
+			for key, hv := range hosts {
+				if len(sortedPaths(hv)) == 0 && hv.DefaultBackend == "" && !hv.Hostless {
+					log.V(1).Info("Skipping host", "host", hv.Host, "reason", "no paths")
+					delete(hosts, key)
 				}
 			}
-			return len(isegs) < len(jsegs)
-		})
+		}
 
-		var sb strings.Builder
-		if err := srvTpl.Execute(&sb, &templateValues{Hosts: hostsList}); err != nil {
-			return reconcile.Result{}, fmt.Errorf("failed to execute page template: %w", err)
+		// Ingress-derived hosts take precedence over static entries for the
+		// same host.
+		for _, link := range opts.extraLinks {
+			key := link.Host
+			if opts.normalizeHosts {
+				key = strings.ToLower(key)
+			}
+			if hosts[key] != nil || !hostAllowed(link.Host, opts.hostAllow, opts.hostDeny) {
+				continue
+			}
+			hosts[key] = &hostValues{
+				Host:  link.Host,
+				URL:   link.URL,
+				Text:  template.HTML(template.HTMLEscapeString(link.Text)),
+				Group: link.Group,
+				Paths: map[string]*pathValues{},
+			}
+		}
+
+		if opts.collapseWWW {
+			collapseWWWHosts(hosts)
+		}
+		if opts.stripSuffix != "" {
+			for _, hv := range hosts {
+				hv.DisplayHost = stripHostSuffix(hv.Host, opts.stripSuffix)
+			}
+		}
+		// Groups can be set by any ingress declaring a host, so the rel is
+		// only known once all ingresses are processed.
+		for _, hv := range hosts {
+			if rel := opts.groupRel[hv.Group]; rel != "" {
+				hv.Rel = rel
+				for _, pv := range hv.Paths {
+					pv.Rel = rel
+				}
+			}
+		}
+		if opts.normalizeHosts {
+			for key, hv := range hosts {
+				if hv.SortKey == "" {
+					hv.SortKey = key
+				}
+			}
+		}
+
+		hostsList := slices.SortedFunc(maps.Values(hosts), compareHosts)
+
+		groupTemplates.prune()
+		if opts.descriptions != nil {
+			opts.descriptions.prune()
+		}
+		if opts.favicons != nil {
+			for _, hv := range hosts {
+				// Static links may have a path, so resolve the favicon
+				// against their URL.
+				if u, err := url.Parse(hv.URL); err == nil && !hv.Hostless {
+					hv.Icon = template.URL(opts.favicons.Get(ctx, u.ResolveReference(&url.URL{Path: "/favicon.ico"}).String()))
+				}
+			}
+			opts.favicons.prune()
+		}
+
+		// QR codes are costly to generate, so reuse those of unchanged URLs
+		// from the previous reconcile.
+		if opts.qr != "" {
+			nextQRCodes := map[string]template.HTML{}
+			for _, hv := range hostsList {
+				if (opts.qr == "primary" && !hv.Primary) || hv.Hostless {
+					continue
+				}
+				svg, ok := qrCodes[hv.URL]
+				if !ok {
+					var err error
+					if svg, err = qrSVG(hv.URL); err != nil {
+						log.V(1).Info("Skipping QR code", "host", hv.Host, "reason", err.Error())
+						continue
+					}
+				}
+				nextQRCodes[hv.URL] = svg
+				hv.QR = svg
+			}
+			qrCodes = nextQRCodes
+		}
+		if opts.page.MaxLinks > 0 && len(hostsList) > opts.page.MaxLinks {
+			log.Info("Too many links, truncating page", "hosts", len(hostsList), "maxLinks", opts.page.MaxLinks)
+		}
+
+		pageOpts := opts.page
+		if opts.configMap.Name != "" {
+			cm := &corev1.ConfigMap{}
+			if err := reader.Get(ctx, opts.configMap, cm); apierrors.IsNotFound(err) {
+				log.V(1).Info("Config map not found, rendering without it", "configMap", opts.configMap)
+			} else if err != nil {
+				return reconcile.Result{}, fmt.Errorf("failed to get config map %s: %w", opts.configMap, err)
+			}
+			pageOpts.Config = cm.Data
 		}
-		page := sb.String()
-		oldPage := pagePtr.Swap(&page)
-		if oldPage == nil {
+
+		page, views, err := renderPage(newTemplateValues(hostsList, pageOpts), opts.views)
+		var classes map[string]string
+		if err == nil && opts.splitByClass {
+			classes, err = renderClassPages(hostsList, pageOpts)
+		}
+		var compact string
+		if err == nil {
+			if compact, err = executePage("", newTemplateValues(compactHosts(hostsList), pageOpts)); err != nil {
+				err = fmt.Errorf("failed to execute page template for compact page: %w", err)
+			}
+		}
+		if opts.renderFailures != nil {
+			if err != nil {
+				opts.renderFailures.Add(1)
+			} else {
+				opts.renderFailures.Store(0)
+			}
+		}
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+
+		recordRender()
+		hash, err := contentHash(page, views, classes, compact, hostsList)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if current := pagePtr.Load(); current != nil && current.ContentHash == hash {
+			log.V(1).Info("Render unchanged, keeping the current page", "generation", current.Generation)
+			return reconcile.Result{RequeueAfter: requeueAfter}, nil
+		}
+		// Every reconcile lists all ingresses and replaces the snapshot
+		// rather than merging into it, so deleted ingresses disappear.
+		generation++
+		oldSnapshot := pagePtr.Swap(&renderSnapshot{
+			Generation:  generation,
+			RenderedAt:  time.Now(),
+			Page:        page,
+			Views:       views,
+			Classes:     classes,
+			Compact:     compact,
+			Hosts:       hostsList,
+			HostsByName: hostsByName(hostsList),
+			Restricted:  restricted,
+			ContentHash: hash,
+			Config:      pageOpts.Config,
+		})
+		if oldSnapshot == nil {
 			log.Info("First reconcile completed")
+		} else {
+			if opts.notifier != nil && oldSnapshot.Page != page {
+				opts.notifier.Notify(diffHosts(oldSnapshot.Hosts, hostsList))
+			}
+			if opts.feed != nil {
+				opts.feed.Record(time.Now(), oldSnapshot.Hosts, hostsList)
+			}
 		}
 
-		return reconcile.Result{}, nil
+		return reconcile.Result{RequeueAfter: requeueAfter}, nil
 	})
 }
 
-func buildServer(log logr.Logger, pagePtr *atomic.Pointer[string]) *http.Server {
+// prettyPathText derives link text from a path, such as Grafana for /grafana
+// or Api/V1 for /api/v1/, by dropping the surrounding slashes and capitalising
+// each word.
+func prettyPathText(path string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range strings.Trim(path, "/") {
+		if upper {
+			r = unicode.ToUpper(r)
+		}
+		sb.WriteRune(r)
+		upper = r == '/' || r == '-' || r == '_' || r == '.' || r == ' '
+	}
+	return sb.String()
+}
+
+// hasPathPrefix reports whether the path is one of the prefixes or below one,
+// matching whole path elements as Prefix ingress paths do, so that /app
+// matches /app/admin but not /application.
+func hasPathPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// labelPathType adds the type of a path to its text if it isn't from a path
+// template, to tell apart the links of an Exact and a Prefix path listed for
+// the same path. Paths are only labelled once.
+func labelPathType(pv *pathValues, prettify bool) {
+	if !pv.defaultText {
+		return
+	}
+	text := pv.Path
+	if prettify {
+		text = prettyPathText(pv.Path)
+	}
+	pv.Text = template.HTML(template.HTMLEscapeString(fmt.Sprintf("%s (%s)", text, pv.PathType)))
+	pv.defaultText = false
+}
+
+// templateTooLarge reports whether a template annotation exceeds the size
+// limit, if set, logging and counting it so that it is skipped before being
+// parsed.
+func templateTooLarge(log logr.Logger, item *netv1.Ingress, kind, annotation, text string, limit int) bool {
+	if limit <= 0 || len(text) <= limit {
+		return false
+	}
+	log.Error(nil, "Skipping template annotation larger than --max-template-size", "annotation", annotation, "size", len(text), "limit", limit)
+	oversizedTemplates.WithLabelValues(item.Namespace, item.Name, kind).Inc()
+	return true
+}
+
+// parseAnnotationTemplate parses a host or path template annotation on a
+// clone of the page templates, so that it can use the named templates without
+// affecting them.
+func parseAnnotationTemplate(tpl *template.Template, text string) (*template.Template, error) {
+	clone, err := tpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return clone.Parse(text)
+}
+
+// groupTemplateCache holds the parsed templates of templated group
+// annotations, keeping those used since the last prune.
+type groupTemplateCache struct {
+	parsed, used map[string]*texttemplate.Template
+}
+
+// execute renders a group annotation as a template against the ingress. Values
+// that fail to parse or execute are used literally.
+func (c *groupTemplateCache) execute(log logr.Logger, text string, item *netv1.Ingress) string {
+	tpl, ok := c.used[text]
+	if !ok {
+		if tpl, ok = c.parsed[text]; !ok {
+			var err error
+			// Missing labels render as an empty group rather than "<no value>".
+			if tpl, err = texttemplate.New("group").Option("missingkey=zero").Parse(text); err != nil {
+				log.V(1).Info("Using group annotation literally", "annotation", groupAnnotation, "reason", err.Error())
+				tpl = nil
+			}
+		}
+		if c.used == nil {
+			c.used = map[string]*texttemplate.Template{}
+		}
+		c.used[text] = tpl
+	}
+	if tpl == nil {
+		return text
+	}
+	var sb strings.Builder
+	if err := tpl.Execute(&sb, item); err != nil {
+		log.Error(err, "Failed to execute group template, using it literally", "annotation", groupAnnotation)
+		templateErrors.WithLabelValues(item.Namespace, item.Name, "group").Inc()
+		return text
+	}
+	return sb.String()
+}
+
+// prune drops the templates not used since the last prune.
+func (c *groupTemplateCache) prune() {
+	c.parsed, c.used = c.used, nil
+}
+
+// renderPage renders the page and each of the views from the same values.
+func renderPage(values *templateValues, viewNames []string) (string, map[string]string, error) {
+	page, err := executePage("", values)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to execute page template: %w", err)
+	}
+
+	views := map[string]string{}
+	for _, view := range viewNames {
+		if views[view], err = executePage(view, values); err != nil {
+			return "", nil, fmt.Errorf("failed to execute template for view %s: %w", view, err)
+		}
+	}
+	return page, views, nil
+}
+
+// executePage renders a full page from the named template, with the root
+// template being unnamed, minifying it if enabled.
+func executePage(name string, values *templateValues) (string, error) {
+	var sb strings.Builder
+	if err := srvTpl.ExecuteTemplate(&sb, name, values); err != nil {
+		return "", err
+	}
+	if values.Options.Minify {
+		return minifyHTML(sb.String()), nil
+	}
+	return sb.String(), nil
+}
+
+// contentHash hashes the rendered pages and the hosts they were rendered from,
+// which are served directly by the endpoints that render per request.
+func contentHash(page string, views, classes map[string]string, compact string, hosts []*hostValues) ([sha256.Size]byte, error) {
+	// Maps are encoded with sorted keys, so the encoding is deterministic.
+	data, err := json.Marshal(struct {
+		Page    string
+		Views   map[string]string
+		Classes map[string]string
+		Compact string
+		Hosts   []*hostValues
+	}{page, views, classes, compact, hosts})
+	if err != nil {
+		return [sha256.Size]byte{}, fmt.Errorf("failed to encode render for hashing: %w", err)
+	}
+	return sha256.Sum256(data), nil
+}
+
+// renderClassPages renders the page separately for the hosts of each ingress
+// class. Hosts without a class only appear on the combined page.
+func renderClassPages(hosts []*hostValues, opts pageOptions) (map[string]string, error) {
+	pages := map[string]string{}
+	for class, classHosts := range hostsByClass(hosts) {
+		page, err := executePage("", newTemplateValues(classHosts, opts))
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute page template for class %s: %w", class, err)
+		}
+		pages[class] = page
+	}
+	return pages, nil
+}
+
+// hostsByClass groups hosts by their ingress class, keeping their order and
+// leaving out hosts without a class.
+func hostsByClass(hosts []*hostValues) map[string][]*hostValues {
+	byClass := map[string][]*hostValues{}
+	for _, hv := range hosts {
+		if hv.Class != "" {
+			byClass[hv.Class] = append(byClass[hv.Class], hv)
+		}
+	}
+	return byClass
+}
+
+// hostsByName indexes hosts by their host name.
+func hostsByName(hosts []*hostValues) map[string]*hostValues {
+	byName := make(map[string]*hostValues, len(hosts))
+	for _, hv := range hosts {
+		byName[hv.Host] = hv
+	}
+	return byName
+}
+
+// compactHosts returns the hosts marked for the compact page.
+func compactHosts(hosts []*hostValues) []*hostValues {
+	var compact []*hostValues
+	for _, hv := range hosts {
+		if hv.Compact {
+			compact = append(compact, hv)
+		}
+	}
+	return compact
+}
+
+// ingressClass returns the class of an ingress from its spec, or from the
+// deprecated annotation used before the spec field existed.
+func ingressClass(item *netv1.Ingress) string {
+	if item.Spec.IngressClassName != nil {
+		return *item.Spec.IngressClassName
+	}
+	return item.Annotations["kubernetes.io/ingress.class"]
+}
+
+// servedPagePath returns the path the page is served on, which moves to /links
+// if the root redirects elsewhere.
+func servedPagePath(pagePath, defaultRedirect string) string {
+	pagePath = cmp.Or(pagePath, "/")
+	if defaultRedirect != "" && pagePath == "/" {
+		return "/links"
+	}
+	return pagePath
+}
+
+// pagePattern returns the pattern serving the page on the path, matched
+// exactly if it ends with a slash.
+func pagePattern(pagePath string) string {
+	if strings.HasSuffix(pagePath, "/") {
+		return "GET " + pagePath + "{$}"
+	}
+	return "GET " + pagePath
+}
+
+// checkPagePath checks that the page path can be served, as registering an
+// invalid pattern panics.
+func checkPagePath(pagePath string) (err error) {
+	if !strings.HasPrefix(pagePath, "/") {
+		return errors.New("must start with /")
+	}
+	if strings.ContainsAny(pagePath, " \t\r\n") {
+		return errors.New("must not contain whitespace")
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid path: %v", r)
+		}
+	}()
+	http.NewServeMux().Handle(pagePattern(pagePath), http.NotFoundHandler())
+	return nil
+}
+
+// hostDetailPrefix returns the prefix of the links to the host pages at
+// /host/{host}, relative to the page so that the links keep working behind a
+// proxy serving the controller under a path. The depth of a page matched by a
+// {rest...} wildcard varies, so its links are absolute.
+func hostDetailPrefix(pagePath string) string {
+	if strings.Contains(pagePath, "...}") {
+		return "/host/"
+	}
+	dir := pagePath[:strings.LastIndex(pagePath, "/")+1]
+	return strings.Repeat("../", strings.Count(dir, "/")-1) + "host/"
+}
+
+func buildServer(log logr.Logger, pagePtr *atomic.Pointer[renderSnapshot], opts serverOptions) *http.Server {
 	mux := http.NewServeMux()
-	mux.Handle("GET /{$}", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
-		page := pagePtr.Load()
-		if page == nil {
-			// not ready yet
-			http.NotFound(rw, req)
-			return
+
+	if opts.defaultRedirect != "" {
+		mux.Handle("GET /{$}", http.RedirectHandler(opts.defaultRedirect, http.StatusFound))
+	}
+
+	// Probes and metrics scrapers don't authenticate either, so the unified
+	// endpoints and the health summary are served to everyone like on the
+	// manager's ports.
+	if opts.unifiedPort {
+		mux.Handle("GET /metrics", promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{ErrorHandling: promhttp.HTTPErrorOnError}))
+		for path, checks := range map[string]map[string]healthz.Checker{"/alive": opts.healthChecks, "/ready": opts.readyChecks} {
+			// Individual checks are served below the path, as by the manager.
+			handler := http.StripPrefix(path, &healthz.Handler{Checks: checks})
+			mux.Handle("GET "+path, handler)
+			mux.Handle("GET "+path+"/", handler)
 		}
+	}
 
-		rw.Header().Add("Content-Type", "text/html")
+	if opts.debugReconcile != nil {
+		mux.Handle("POST /debug/reconcile", requireForwardedUser(opts.requireForwardedUser, debugReconcileHandler(log, pagePtr, opts.debugReader, opts.debugReconcile)))
+	}
+
+	// Unlike the probe endpoints, the summary isn't needed by the kubelet,
+	// and its check errors and page state are only for those who can see the
+	// page.
+	mux.Handle("GET /healthz/summary", requireForwardedUser(opts.requireForwardedUser, healthSummaryHandler(pagePtr, opts.healthChecks, opts.readyChecks)))
+
+	// Crawlers don't authenticate, so robots.txt is served to everyone.
+	robotsTxt := opts.robotsTxt
+	if len(robotsTxt) == 0 {
+		robotsTxt = []byte("User-agent: *\nDisallow: /\n")
+	}
+	mux.HandleFunc("GET /robots.txt", func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Add("Content-Type", "text/plain; charset=utf-8")
 		rw.WriteHeader(http.StatusOK)
-		if _, err := io.Copy(rw, strings.NewReader(*page)); err != nil {
+		if _, err := rw.Write(robotsTxt); err != nil {
 			panic(err.Error())
 		}
-	}))
-	return &http.Server{Handler: mux}
-}
+	})
 
-func usage() {
-	fmt.Fprintf(flag.CommandLine.Output(), "Flags for %s:\n", filepath.Base(os.Args[0]))
-	flag.PrintDefaults()
-	fmt.Fprintln(flag.CommandLine.Output(), "The current templates are:")
-	var names []string
-	for _, tpl := range srvTpl.Templates() {
-		names = append(names, tpl.Name())
-	}
-	slices.Sort(names)
-	for _, name := range names {
-		tpl := srvTpl.Lookup(name)
-		if name != "" {
-			fmt.Fprintf(flag.CommandLine.Output(), "  %q:\n\t", name)
-		} else {
-			fmt.Fprintf(flag.CommandLine.Output(), "  root:\n\t")
+	mux.Handle("GET /summary", requireForwardedUser(opts.requireForwardedUser, func(rw http.ResponseWriter, req *http.Request) {
+		snapshot := pagePtr.Load()
+		if snapshot == nil {
+			jsonError(rw, "not ready", http.StatusServiceUnavailable)
+			return
 		}
-		fmt.Fprintln(flag.CommandLine.Output(), strings.ReplaceAll(strings.Trim(tpl.Tree.Root.String(), "\n"), "\n", "\n\t"))
+
+		rw.Header().Add("Content-Type", "text/plain; charset=utf-8")
+		rw.WriteHeader(http.StatusOK)
+		writeSummary(rw, snapshot.visibleTo(req))
+	}))
+
+	if opts.feed != nil {
+		mux.Handle("GET /feed.atom", requireForwardedUser(opts.requireForwardedUser, func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Add("Content-Type", "application/atom+xml; charset=utf-8")
+			rw.WriteHeader(http.StatusOK)
+			if err := opts.feed.WriteAtom(rw, forwardedGroups(req)); err != nil {
+				panic(err.Error())
+			}
+		}))
 	}
+
+	if opts.sitemap {
+		mux.Handle("GET /sitemap.xml", requireForwardedUser(opts.requireForwardedUser, func(rw http.ResponseWriter, req *http.Request) {
+			snapshot := pagePtr.Load()
+			if snapshot == nil {
+				jsonError(rw, "not ready", http.StatusServiceUnavailable)
+				return
+			}
+
+			rw.Header().Add("Content-Type", "application/xml; charset=utf-8")
+			rw.WriteHeader(http.StatusOK)
+			if err := writeSitemap(rw, snapshot.visibleTo(req), snapshot.RenderedAt); err != nil {
+				panic(err.Error())
+			}
+		}))
+	}
+
+	if len(opts.embedFrameAncestors) > 0 {
+		// X-Frame-Options can't list origins, so it's only set when framing
+		// is limited to the same origin, for browsers without CSP support.
+		frameAncestors := "frame-ancestors " + strings.Join(opts.embedFrameAncestors, " ")
+		sameOrigin := slices.Equal(opts.embedFrameAncestors, []string{"'self'"})
+		mux.Handle("GET /embed", requireForwardedUser(opts.requireForwardedUser, func(rw http.ResponseWriter, req *http.Request) {
+			snapshot := pagePtr.Load()
+			if snapshot == nil {
+				// not ready yet
+				http.NotFound(rw, req)
+				return
+			}
+
+			// Only the body is rendered, for the embedding page to style.
+			page, err := executePage("body", newTemplateValues(snapshot.visibleTo(req), snapshot.pageOptions(opts.page)))
+			if err != nil {
+				log.Error(err, "Failed to execute body template for embedding")
+				http.Error(rw, "failed to render page", http.StatusInternalServerError)
+				return
+			}
+
+			rw.Header().Add("Content-Type", "text/html")
+			rw.Header().Add("Content-Security-Policy", frameAncestors)
+			if sameOrigin {
+				rw.Header().Add("X-Frame-Options", "SAMEORIGIN")
+			}
+			rw.Header().Add("Last-Modified", snapshot.RenderedAt.UTC().Format(http.TimeFormat))
+			rw.WriteHeader(http.StatusOK)
+			if _, err := io.Copy(rw, strings.NewReader(page)); err != nil {
+				panic(err.Error())
+			}
+		}))
+	}
+
+	mux.Handle("GET /links.md", requireForwardedUser(opts.requireForwardedUser, func(rw http.ResponseWriter, req *http.Request) {
+		snapshot := pagePtr.Load()
+		if snapshot == nil {
+			jsonError(rw, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+
+		rw.Header().Add("Content-Type", "text/markdown; charset=utf-8")
+		rw.WriteHeader(http.StatusOK)
+		if err := writeMarkdown(rw, snapshot.visibleTo(req)); err != nil {
+			panic(err.Error())
+		}
+	}))
+
+	mux.Handle("GET /host/{host}", requireForwardedUser(opts.requireForwardedUser, func(rw http.ResponseWriter, req *http.Request) {
+		snapshot := pagePtr.Load()
+		if snapshot == nil {
+			// not ready yet
+			http.NotFound(rw, req)
+			return
+		}
+
+		hv := snapshot.HostsByName[req.PathValue("host")]
+		if hv == nil {
+			http.NotFound(rw, req)
+			return
+		}
+		hosts := []*hostValues{hv}
+		if snapshot.Restricted {
+			if hosts = visibleHosts(hosts, forwardedGroups(req)); len(hosts) == 0 {
+				http.NotFound(rw, req)
+				return
+			}
+		}
+
+		// Show all of the host's paths.
+		pageOpts := snapshot.pageOptions(opts.page)
+		pageOpts.MaxPathsPerHost = 0
+		page, err := executePage("hostdetail", newTemplateValues(hosts, pageOpts))
+		if err != nil {
+			log.Error(err, "Failed to execute host detail template", "host", hv.Host)
+			http.Error(rw, "failed to render page", http.StatusInternalServerError)
+			return
+		}
+
+		rw.Header().Add("Content-Type", "text/html")
+		rw.WriteHeader(http.StatusOK)
+		if _, err := io.Copy(rw, strings.NewReader(page)); err != nil {
+			panic(err.Error())
+		}
+	}))
+
+	mux.Handle("GET /class/{class}", requireForwardedUser(opts.requireForwardedUser, func(rw http.ResponseWriter, req *http.Request) {
+		snapshot := pagePtr.Load()
+		if snapshot == nil {
+			// not ready yet
+			http.NotFound(rw, req)
+			return
+		}
+
+		class := req.PathValue("class")
+		page, ok := snapshot.Classes[class]
+		if !ok {
+			http.NotFound(rw, req)
+			return
+		}
+
+		if snapshot.Restricted {
+			var err error
+			if page, err = executePage("", newTemplateValues(hostsByClass(snapshot.visibleTo(req))[class], snapshot.pageOptions(opts.page))); err != nil {
+				log.Error(err, "Failed to execute page template for class", "class", class)
+				http.Error(rw, "failed to render page", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		rw.Header().Add("Content-Type", "text/html")
+		rw.WriteHeader(http.StatusOK)
+		if _, err := io.Copy(rw, strings.NewReader(page)); err != nil {
+			panic(err.Error())
+		}
+	}))
+
+	mux.Handle("GET /compact", requireForwardedUser(opts.requireForwardedUser, func(rw http.ResponseWriter, req *http.Request) {
+		snapshot := pagePtr.Load()
+		if snapshot == nil {
+			// not ready yet
+			http.NotFound(rw, req)
+			return
+		}
+
+		page := snapshot.Compact
+		if snapshot.Restricted {
+			var err error
+			if page, err = executePage("", newTemplateValues(compactHosts(snapshot.visibleTo(req)), snapshot.pageOptions(opts.page))); err != nil {
+				log.Error(err, "Failed to execute page template for compact page")
+				http.Error(rw, "failed to render page", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		rw.Header().Add("Content-Type", "text/html")
+		rw.WriteHeader(http.StatusOK)
+		if _, err := io.Copy(rw, strings.NewReader(page)); err != nil {
+			panic(err.Error())
+		}
+	}))
+
+	mux.Handle(pagePattern(servedPagePath(opts.pagePath, opts.defaultRedirect)), requireForwardedUser(opts.requireForwardedUser, func(rw http.ResponseWriter, req *http.Request) {
+		snapshot := pagePtr.Load()
+		if snapshot == nil {
+			// not ready yet
+			http.NotFound(rw, req)
+			return
+		}
+
+		// The root template is unnamed, so the default view is the empty name.
+		view := req.URL.Query().Get("view")
+		page, ok := snapshot.Page, true
+		if view != "" {
+			page, ok = snapshot.Views[view]
+		}
+		if !ok {
+			http.NotFound(rw, req)
+			return
+		}
+
+		if snapshot.Restricted {
+			var err error
+			if page, err = executePage(view, newTemplateValues(snapshot.visibleTo(req), snapshot.pageOptions(opts.page))); err != nil {
+				log.Error(err, "Failed to execute page template for request")
+				http.Error(rw, "failed to render page", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		rw.Header().Add("Content-Type", "text/html")
+		rw.Header().Add("Last-Modified", snapshot.RenderedAt.UTC().Format(http.TimeFormat))
+		rw.WriteHeader(http.StatusOK)
+		if _, err := io.Copy(rw, strings.NewReader(page)); err != nil {
+			panic(err.Error())
+		}
+	}))
+	// Request contexts are cancelled when shutdown starts, so that long-lived
+	// handlers can return rather than holding up a graceful shutdown until
+	// the shutdown timeout.
+	baseCtx, cancel := context.WithCancel(context.Background())
+	srv := &http.Server{
+		Handler:     mux,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+	srv.RegisterOnShutdown(cancel)
+	if opts.tlsConfig != nil {
+		srv.Addr = ":https"
+		srv.TLSConfig = opts.tlsConfig
+	}
+	return srv
+}
+
+// healthCheckResult is the result of a single check in the health summary.
+type healthCheckResult struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// healthSummaryHandler serves the results of all the liveness and readiness
+// checks as JSON, along with the state of the page, so that a controller
+// that isn't ready can be diagnosed with a single request. The checks are run
+// for each request, as the manager's probe endpoints do.
+func healthSummaryHandler(pagePtr *atomic.Pointer[renderSnapshot], healthChecks, readyChecks map[string]healthz.Checker) http.HandlerFunc {
+	run := func(req *http.Request, checks map[string]healthz.Checker) (bool, []healthCheckResult) {
+		ok := true
+		results := []healthCheckResult{}
+		for _, name := range slices.Sorted(maps.Keys(checks)) {
+			result := healthCheckResult{Name: name, OK: true}
+			if err := checks[name](req); err != nil {
+				result.OK, result.Error = false, err.Error()
+				ok = false
+			}
+			results = append(results, result)
+		}
+		return ok, results
+	}
+	return func(rw http.ResponseWriter, req *http.Request) {
+		type checksSummary struct {
+			OK     bool                `json:"ok"`
+			Checks []healthCheckResult `json:"checks"`
+		}
+		var summary struct {
+			Alive      checksSummary `json:"alive"`
+			Ready      checksSummary `json:"ready"`
+			HavePage   bool          `json:"havePage"`
+			Generation uint64        `json:"generation,omitempty"`
+			// LastRender is the time of the last successful render, which
+			// may have left the page unchanged.
+			LastRender *time.Time `json:"lastRender,omitempty"`
+		}
+		summary.Alive.OK, summary.Alive.Checks = run(req, healthChecks)
+		summary.Ready.OK, summary.Ready.Checks = run(req, readyChecks)
+		if snapshot := pagePtr.Load(); snapshot != nil {
+			summary.HavePage = true
+			summary.Generation = snapshot.Generation
+			last := time.Unix(0, lastRender.Load()).UTC()
+			summary.LastRender = &last
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(rw).Encode(summary)
+	}
+}
+
+// jsonError replies with a JSON error body, for endpoints consumed by
+// programs rather than browsers.
+func jsonError(rw http.ResponseWriter, message string, code int) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	rw.WriteHeader(code)
+	_ = json.NewEncoder(rw).Encode(struct {
+		Error string `json:"error"`
+	}{Error: message})
+}
+
+func forwardedGroups(req *http.Request) []string {
+	var groups []string
+	for _, value := range req.Header.Values("X-Forwarded-Groups") {
+		groups = append(groups, parseAllowedGroups(value)...)
+	}
+	return groups
+}
+
+// requireForwardedUser rejects requests without the user header set by an
+// auth proxy, if required.
+func requireForwardedUser(required bool, handler http.HandlerFunc) http.Handler {
+	if !required {
+		return handler
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-Forwarded-User") == "" {
+			http.Error(rw, "missing X-Forwarded-User header", http.StatusUnauthorized)
+			return
+		}
+		handler(rw, req)
+	})
+}
+
+// writeSummary writes a table of the number of links per namespace, counting
+// both host and path links as shown on the page.
+func writeSummary(w io.Writer, hosts []*hostValues) {
+	counts := map[string]int{}
+	total := 0
+	for _, hv := range hosts {
+		counts[hv.Namespace]++
+		total++
+		for _, pv := range hv.Paths {
+			if pv.Path != "/" {
+				counts[pv.Namespace]++
+				total++
+			}
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tLINKS")
+	for _, namespace := range slices.Sorted(maps.Keys(counts)) {
+		fmt.Fprintf(tw, "%s\t%d\n", cmp.Or(namespace, "(static)"), counts[namespace])
+	}
+	fmt.Fprintf(tw, "TOTAL\t%d\n", total)
+	_ = tw.Flush()
+}
+
+// parseCipherSuite looks up a cipher suite by its standard name, rejecting
+// suites with known security issues.
+func parseCipherSuite(name string) (uint16, error) {
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name == name {
+			return suite.ID, nil
+		}
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		if suite.Name == name {
+			return 0, fmt.Errorf("cipher suite %s is insecure", name)
+		}
+	}
+	return 0, fmt.Errorf("unknown cipher suite %q", name)
+}
+
+// buildTLSConfig loads the serving certificate and, if a client CA is given,
+// requires clients to present a certificate signed by it. It returns nil if
+// TLS is not configured.
+func buildTLSConfig(certFile, keyFile, clientCAFile string, minVersion uint16, cipherSuites []uint16) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		if clientCAFile != "" {
+			return nil, errors.New("--client-ca requires --tls-cert and --tls-key")
+		}
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   minVersion,
+		CipherSuites: cipherSuites,
+	}
+
+	if clientCAFile != "" {
+		caPEM, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in client CA %s", clientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, nil
+}
+
+// pinRanks sorts hosts by their pin annotation, with unpinned hosts between.
+var pinRanks = map[string]int{"top": -1, "bottom": 1}
+
+// compareHosts sorts hosts pinned to the top first and those pinned to the
+// bottom last, then primary hosts first, then by the order annotation, and
+// then by each segment of the domains starting from the TLD, i.e. the last
+// segment. Meaning: Subdomains of the same domain are grouped together, and
+// subdomains come after their parent domain if present. A host's sort key
+// annotation is compared in place of its domain. Hosts that still tie are
+// sorted oldest first, so that new services don't reorder existing ones.
+func compareHosts(a, b *hostValues) int {
+	if c := cmp.Compare(pinRanks[a.Pin], pinRanks[b.Pin]); c != 0 {
+		return c
+	}
+	if a.Primary != b.Primary {
+		if a.Primary {
+			return -1
+		}
+		return 1
+	}
+	if c := cmp.Compare(a.Order, b.Order); c != 0 {
+		return c
+	}
+
+	asegs, bsegs := strings.Split(cmp.Or(a.SortKey, a.Host), "."), strings.Split(cmp.Or(b.SortKey, b.Host), ".")
+	for ridx := 0; ridx < len(asegs) && ridx < len(bsegs); ridx++ {
+		aseg, bseg := asegs[len(asegs)-ridx-1], bsegs[len(bsegs)-ridx-1]
+		if c := strings.Compare(aseg, bseg); c != 0 {
+			return c
+		}
+	}
+	if c := cmp.Compare(len(asegs), len(bsegs)); c != 0 {
+		return c
+	}
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	// Hosts sharing a sort key still need a consistent order.
+	return strings.Compare(a.Host, b.Host)
+}
+
+// compareGroupHosts sorts the hosts within a group. The order annotation ranks
+// the members of a group ahead of whether they are primary, so that a group is
+// sorted by its members' order and then by domain, as with compareHosts. Pins
+// still keep hosts at the top or bottom of their group.
+func compareGroupHosts(a, b *hostValues) int {
+	if c := cmp.Compare(pinRanks[a.Pin], pinRanks[b.Pin]); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.Order, b.Order); c != 0 {
+		return c
+	}
+	return compareHosts(a, b)
+}
+
+// newHostlessValues returns the entry listing an ingress without a host under
+// its namespace and name.
+func newHostlessValues(item *netv1.Ingress, group string, allowedGroups []string) *hostValues {
+	hv := &hostValues{
+		Host:          item.Namespace + "/" + item.Name,
+		Namespace:     item.Namespace,
+		Class:         ingressClass(item),
+		CreatedAt:     item.CreationTimestamp.Time,
+		Group:         group,
+		Tooltip:       item.Annotations[tooltipAnnotation],
+		Paths:         map[string]*pathValues{},
+		AllowedGroups: allowedGroups,
+		Hostless:      true,
+	}
+	if hv.Tooltip == "" && item.Spec.DefaultBackend != nil {
+		hv.Tooltip = describeBackend(item.Spec.DefaultBackend)
+	}
+	return hv
+}
+
+// setProvenance records the ingress that declared the host, so that a link
+// can be traced back to the object it was rendered from.
+func setProvenance(hv *hostValues, item *netv1.Ingress) {
+	hv.UID = string(item.UID)
+	hv.ResourceVersion = item.ResourceVersion
+}
+
+// tlsHosts returns the hosts of the ingress's first TLS entry covering the
+// host, with wildcards matching a single label, or nil if there is none.
+func tlsHosts(item *netv1.Ingress, host string) []string {
+	_, parent, _ := strings.Cut(host, ".")
+	for _, tls := range item.Spec.TLS {
+		for _, tlsHost := range tls.Hosts {
+			wildcard, isWildcard := strings.CutPrefix(tlsHost, "*.")
+			if strings.EqualFold(tlsHost, host) || (isWildcard && parent != "" && strings.EqualFold(wildcard, parent)) {
+				return tls.Hosts
+			}
+		}
+	}
+	return nil
+}
+
+// loadBalancerAddress returns the first address assigned to an ingress, if
+// any.
+func loadBalancerAddress(item *netv1.Ingress) string {
+	for _, lb := range item.Status.LoadBalancer.Ingress {
+		if address := cmp.Or(lb.Hostname, lb.IP); address != "" {
+			return address
+		}
+	}
+	return ""
+}
+
+// describeBackend names the service and port, or the resource, of a backend.
+func describeBackend(backend *netv1.IngressBackend) string {
+	switch {
+	case backend.Service != nil && backend.Service.Port.Name != "":
+		return "service " + backend.Service.Name + ":" + backend.Service.Port.Name
+	case backend.Service != nil:
+		return fmt.Sprintf("service %s:%d", backend.Service.Name, backend.Service.Port.Number)
+	case backend.Resource != nil:
+		return "resource " + backend.Resource.Kind + "/" + backend.Resource.Name
+	}
+	return "unknown backend"
+}
+
+// dataAttributes collects the data-* annotations of an ingress. Names are
+// restricted to lowercase letters, digits and dashes so they are valid
+// attribute names; values are escaped by the template.
+func dataAttributes(log logr.Logger, annotations map[string]string) map[string]string {
+	var attrs map[string]string
+	for key, value := range annotations {
+		name, found := strings.CutPrefix(key, dataAnnotationPrefix)
+		if !found {
+			continue
+		}
+		if name == "" || strings.ContainsFunc(name, func(r rune) bool {
+			return !('a' <= r && r <= 'z' || '0' <= r && r <= '9' || r == '-')
+		}) {
+			log.Info("Ignoring data annotation with invalid attribute name", "annotation", key)
+			continue
+		}
+		if attrs == nil {
+			attrs = map[string]string{}
+		}
+		attrs[name] = value
+	}
+	return attrs
+}
+
+// parsePort validates a port annotation, returning an empty port if the value
+// is empty.
+func parsePort(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	port, err := strconv.ParseUint(value, 10, 16)
+	if err != nil || port == 0 {
+		return "", fmt.Errorf("invalid port %q", value)
+	}
+	return strconv.FormatUint(port, 10), nil
+}
+
+// hostAllowed reports whether a host passes the --host-allow and --host-deny
+// glob patterns. Deny patterns take precedence, and no allow patterns means all
+// hosts are allowed.
+func hostAllowed(host string, allow, deny []string) bool {
+	matches := func(pattern string) bool {
+		matched, _ := path.Match(pattern, host)
+		return matched
+	}
+	if slices.ContainsFunc(deny, matches) {
+		return false
+	}
+	return len(allow) == 0 || slices.ContainsFunc(allow, matches)
+}
+
+// hostURL builds the link target for a host, including the port if it is not
+// the standard one for the scheme, and using http:// for insecure hosts. IPv6
+// literals are bracketed so they are not mistaken for a port.
+func hostURL(host, port string, insecure bool) string {
+	if addr, err := netip.ParseAddr(host); err == nil && addr.Is6() {
+		host = "[" + strings.ReplaceAll(host, "%", "%25") + "]"
+	}
+	standard := "443"
+	if insecure {
+		standard = "80"
+	}
+	if port != "" && port != standard {
+		host += ":" + port
+	}
+	if insecure {
+		return "http://" + host
+	}
+	return "https://" + host
+}
+
+// stripHostSuffix removes a domain suffix from a host, ignoring case and
+// whether the suffix starts with a dot. Hosts that are the suffix itself are
+// returned unchanged.
+func stripHostSuffix(host, suffix string) string {
+	suffix = "." + strings.TrimPrefix(suffix, ".")
+	if len(host) > len(suffix) && strings.EqualFold(host[len(host)-len(suffix):], suffix) {
+		return host[:len(host)-len(suffix)]
+	}
+	return host
+}
+
+// collapseWWWHosts merges each www. host into its apex domain if both are
+// present. The apex is canonical: its link, text, group and paths take
+// precedence, with the www. host's text, group and non-colliding paths used to
+// fill in anything the apex lacks.
+func collapseWWWHosts(hosts map[string]*hostValues) {
+	for _, host := range slices.Sorted(maps.Keys(hosts)) {
+		www := hosts[host]
+		apex := hosts[strings.TrimPrefix(host, "www.")]
+		if www == nil || apex == nil || apex == www {
+			continue
+		}
+		if apex.Text == "" {
+			apex.Text = www.Text
+		}
+		if apex.Group == "" {
+			apex.Group = www.Group
+		}
+		apex.Collapse = apex.Collapse || www.Collapse
+		apex.AllowedGroups = mergeAllowedGroups(apex.AllowedGroups, www.AllowedGroups)
+		for path, pv := range www.Paths {
+			if apex.Paths[path] == nil {
+				apex.Paths[path] = pv
+			}
+		}
+		delete(hosts, host)
+	}
+}
+
+// newTemplateValues truncates the sorted hosts as configured and buckets them
+// into groups, keeping the hosts' order within each group. Ungrouped hosts
+// come first, followed by the named groups in alphabetical order.
+func newTemplateValues(hosts []*hostValues, opts pageOptions) *templateValues {
+	values := &templateValues{Options: opts, TotalHosts: len(hosts)}
+	for _, hv := range hosts {
+		values.Confirm = values.Confirm || hv.Confirm != ""
+		values.Schemes = values.Schemes || len(hv.Schemes) > 1
+		values.Hostless = values.Hostless || hv.Hostless
+		for _, pv := range hv.Paths {
+			if pv.Path != "/" {
+				values.TotalPaths++
+				values.Confirm = values.Confirm || pv.Confirm != ""
+			}
+		}
+	}
+	if opts.MaxLinks > 0 && len(hosts) > opts.MaxLinks {
+		values.MoreHosts = len(hosts) - opts.MaxLinks
+		hosts = hosts[:opts.MaxLinks]
+	}
+	if opts.MaxPathsPerHost > 0 {
+		hosts = truncatePaths(hosts, opts.MaxPathsPerHost)
+		// Truncated hosts are copies, so can be modified.
+		for _, hv := range hosts {
+			if hv.MorePaths > 0 {
+				hv.DetailURL = cmp.Or(opts.HostDetailPrefix, "host/") + url.PathEscape(hv.Host)
+			}
+		}
+	}
+	values.Hosts = hosts
+
+	hosts = slices.DeleteFunc(slices.Clone(hosts), func(hv *hostValues) bool {
+		if hv.DefaultBackend != "" {
+			values.DefaultBackends = append(values.DefaultBackends, hv)
+		}
+		return hv.DefaultBackend != ""
+	})
+	values.Groups = groupTree(hosts, opts.GroupOrder, opts.DefaultGroupName, opts.DefaultGroupLast)
+	if opts.TOC {
+		for _, group := range flattenGroups(values.Groups) {
+			if group.Name != "" {
+				values.TOC = append(values.TOC, group)
+			}
+		}
+		setGroupAnchors(values.TOC)
+	}
+	if opts.OpenAll {
+		for _, group := range flattenGroups(values.Groups) {
+			group.OpenAllURLs = openAllURLs(group.Hosts)
+		}
+	}
+	return values
+}
+
+// openAllURLs returns the space-separated URLs of the hosts and their listed
+// paths, leaving out links that ask for confirmation before being followed.
+// Unlike in href attributes, the template doesn't filter the URLs in the
+// button's data attribute, so only HTTP URLs are included.
+func openAllURLs(hosts []*hostValues) string {
+	var urls []string
+	add := func(u, confirm string) {
+		if confirm == "" && (strings.HasPrefix(u, "https://") || strings.HasPrefix(u, "http://")) && !slices.Contains(urls, u) {
+			urls = append(urls, u)
+		}
+	}
+	for _, hv := range hosts {
+		add(hv.URL, hv.Confirm)
+		for _, pv := range sortedPaths(hv) {
+			add(pv.URL, pv.Confirm)
+		}
+	}
+	return strings.Join(urls, " ")
+}
+
+// setGroupAnchors derives fragment identifiers from the group paths, numbering
+// groups whose paths collide after being reduced to lowercase letters, digits
+// and dashes.
+func setGroupAnchors(groups []*groupValues) {
+	used := map[string]bool{}
+	for _, group := range groups {
+		var sb strings.Builder
+		dash := false
+		for _, r := range strings.ToLower(group.Path) {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				if dash && sb.Len() > 0 {
+					sb.WriteByte('-')
+				}
+				sb.WriteRune(r)
+				dash = false
+			} else {
+				dash = true
+			}
+		}
+		base := "group-" + sb.String()
+		if sb.Len() == 0 {
+			base = "group"
+		}
+		anchor := base
+		for i := 2; used[anchor]; i++ {
+			anchor = fmt.Sprintf("%s-%d", base, i)
+		}
+		used[anchor] = true
+		group.Anchor = anchor
+	}
+}
+
+// groupTree arranges hosts into nested groups by splitting their group
+// annotations on "/". Empty segments are ignored, so a group that consists only
+// of slashes is treated as no group. Groups whose paths are listed in order come
+// first in that order, followed by the others alphabetically. Hosts without a
+// group come first, or last if ungroupedLast is set, and are given the
+// ungroupedName as a heading if set and there are other groups. Ungrouped hosts
+// keep the order they are given in, while the hosts of each group are sorted by
+// compareGroupHosts.
+func groupTree(hosts []*hostValues, order []string, ungroupedName string, ungroupedLast bool) []*groupValues {
+	root := &groupValues{}
+	groups := map[string]*groupValues{"": root}
+	for _, hv := range hosts {
+		group := root
+		for _, name := range strings.Split(hv.Group, "/") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			path := name
+			if group != root {
+				path = group.Path + "/" + name
+			}
+			if groups[path] == nil {
+				groups[path] = &groupValues{Name: name, Path: path, Depth: len(strings.Split(path, "/")) - 1}
+				group.Groups = append(group.Groups, groups[path])
+			}
+			group = groups[path]
+		}
+		group.Hosts = append(group.Hosts, hv)
+	}
+	for _, group := range groups {
+		if group != root {
+			slices.SortFunc(group.Hosts, compareGroupHosts)
+		}
+		slices.SortFunc(group.Groups, func(a, b *groupValues) int {
+			return cmp.Or(compareGroupOrder(order, a.Path, b.Path), strings.Compare(a.Name, b.Name))
+		})
+	}
+	top := root.Groups
+	root.Groups = nil
+	if len(root.Hosts) == 0 {
+		return top
+	}
+	if len(top) > 0 {
+		root.Name, root.Path = ungroupedName, ungroupedName
+	}
+	if ungroupedLast {
+		return append(top, root)
+	}
+	return append([]*groupValues{root}, top...)
+}
+
+// compareGroupOrder sorts group paths listed in order before those that
+// aren't, and listed paths by their position.
+func compareGroupOrder(order []string, a, b string) int {
+	ai, bi := slices.Index(order, a), slices.Index(order, b)
+	switch {
+	case ai < 0 && bi < 0:
+		return 0
+	case ai < 0:
+		return 1
+	case bi < 0:
+		return -1
+	}
+	return cmp.Compare(ai, bi)
+}
+
+// flattenGroups lists groups and their subgroups depth-first, in the order
+// they are rendered.
+func flattenGroups(groups []*groupValues) []*groupValues {
+	var flat []*groupValues
+	for _, group := range groups {
+		flat = append(flat, group)
+		flat = append(flat, flattenGroups(group.Groups)...)
+	}
+	return flat
+}
+
+// sortedPaths returns the paths of a host that are shown on the page, in the
+// order they are rendered.
+func sortedPaths(hv *hostValues) []*pathValues {
+	var paths []*pathValues
+	for _, path := range slices.Sorted(maps.Keys(hv.Paths)) {
+		if path != "/" {
+			paths = append(paths, hv.Paths[path])
+		}
+	}
+	return paths
+}
+
+// truncatePaths limits each host to its first limit paths in the order they are
+// rendered, copying any host that is truncated so that the full paths remain
+// available for the host's own page. The root path is not rendered so it does
+// not count towards the limit.
+func truncatePaths(hosts []*hostValues, limit int) []*hostValues {
+	truncated := make([]*hostValues, 0, len(hosts))
+	for _, hv := range hosts {
+		var paths []string
+		for path := range hv.Paths {
+			if path != "/" {
+				paths = append(paths, path)
+			}
+		}
+		if len(paths) <= limit {
+			truncated = append(truncated, hv)
+			continue
+		}
+		slices.Sort(paths)
+
+		hvCopy := *hv
+		hvCopy.Paths = map[string]*pathValues{}
+		for _, path := range paths[:limit] {
+			hvCopy.Paths[path] = hv.Paths[path]
+		}
+		hvCopy.MorePaths = len(paths) - limit
+		truncated = append(truncated, &hvCopy)
+	}
+	return truncated
+}
+
+// loadExtraLinks reads a list of static link entries from a YAML or JSON file.
+func loadExtraLinks(path string) ([]extraLink, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var links []extraLink
+	if err := yaml.UnmarshalStrict(data, &links); err != nil {
+		return nil, fmt.Errorf("failed to parse extra links from %s: %w", path, err)
+	}
+	for i := range links {
+		link := &links[i]
+		if link.URL == "" {
+			return nil, fmt.Errorf("extra link %d in %s has no url", i, path)
+		}
+		if link.Host == "" {
+			u, err := url.Parse(link.URL)
+			if err != nil {
+				return nil, fmt.Errorf("extra link %d in %s has an invalid url: %w", i, path, err)
+			}
+			link.Host = u.Host
+		}
+		if link.Host == "" {
+			return nil, fmt.Errorf("extra link %d in %s has no host", i, path)
+		}
+	}
+	return links, nil
+}
+
+// parseExtraURLs parses a comma-separated list of absolute URLs. The URLs are
+// trusted for use in links, as their schemes are not known to html/template,
+// so script-capable schemes are rejected.
+func parseExtraURLs(value string) ([]template.URL, error) {
+	var urls []template.URL
+	for _, raw := range strings.Split(value, ",") {
+		if raw = strings.TrimSpace(raw); raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil {
+			return urls, err
+		}
+		switch strings.ToLower(u.Scheme) {
+		case "":
+			return urls, fmt.Errorf("URL %q has no scheme", raw)
+		case "javascript", "vbscript", "data":
+			return urls, fmt.Errorf("URL %q has disallowed scheme %s", raw, u.Scheme)
+		}
+		urls = append(urls, template.URL(u.String()))
+	}
+	return urls, nil
+}
+
+// parseSchemes parses a comma-separated list of the schemes to link to a host
+// with, in order.
+func parseSchemes(value string) ([]string, error) {
+	var schemes []string
+	for _, scheme := range strings.Split(value, ",") {
+		scheme = strings.ToLower(strings.TrimSpace(scheme))
+		switch {
+		case scheme == "":
+		case scheme != "http" && scheme != "https":
+			return nil, fmt.Errorf("unsupported scheme %q", scheme)
+		case !slices.Contains(schemes, scheme):
+			schemes = append(schemes, scheme)
+		}
+	}
+	return schemes, nil
+}
+
+// parseAllowedGroups splits a comma-separated list of groups, returning nil if
+// there are none.
+func parseAllowedGroups(value string) []string {
+	var groups []string
+	for _, group := range strings.Split(value, ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// mergeAllowedGroups combines the groups of two ingresses contributing the
+// same link. The link is visible if either ingress allows it, so an
+// unrestricted ingress makes the link visible to everyone.
+func mergeAllowedGroups(a, b []string) []string {
+	if a == nil || b == nil {
+		return nil
+	}
+	merged := slices.Concat(a, b)
+	slices.Sort(merged)
+	return slices.Compact(merged)
+}
+
+func allowedFor(allowedGroups, groups []string) bool {
+	return allowedGroups == nil || slices.ContainsFunc(groups, func(group string) bool {
+		return slices.Contains(allowedGroups, group)
+	})
+}
+
+// visibleHosts filters hosts and their paths down to those visible to a user
+// in the given groups, copying any host whose paths are filtered.
+func visibleHosts(hosts []*hostValues, groups []string) []*hostValues {
+	var visible []*hostValues
+	for _, hv := range hosts {
+		if !allowedFor(hv.AllowedGroups, groups) {
+			continue
+		}
+		filtered := *hv
+		filtered.Paths = map[string]*pathValues{}
+		for key, pv := range hv.Paths {
+			if allowedFor(pv.AllowedGroups, groups) {
+				filtered.Paths[key] = pv
+			}
+		}
+		visible = append(visible, &filtered)
+	}
+	return visible
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags]\n", filepath.Base(os.Args[0]))
+	fmt.Fprintf(flag.CommandLine.Output(), "       %s validate-template --ingress file [--host-template tpl] [--path-template tpl]\n", filepath.Base(os.Args[0]))
+	fmt.Fprintf(flag.CommandLine.Output(), "       %s list [--context name] [-o table|json|yaml] [link flags]\n", filepath.Base(os.Args[0]))
+	fmt.Fprintf(flag.CommandLine.Output(), "Flags for %s:\n", filepath.Base(os.Args[0]))
+	flag.PrintDefaults()
+	fmt.Fprintln(flag.CommandLine.Output(), "The current templates are:")
+	for _, name := range templateNames(srvTpl) {
+		tpl := srvTpl.Lookup(name)
+		if name != "" {
+			fmt.Fprintf(flag.CommandLine.Output(), "  %q:\n\t", name)
+		} else {
+			fmt.Fprintf(flag.CommandLine.Output(), "  root:\n\t")
+		}
+		fmt.Fprintln(flag.CommandLine.Output(), strings.ReplaceAll(strings.Trim(tpl.Tree.Root.String(), "\n"), "\n", "\n\t"))
+	}
+}
+
+// templateNames lists the names of the templates in sorted order, with the
+// root template first as its name is empty.
+func templateNames(tpl *template.Template) []string {
+	var names []string
+	for _, t := range tpl.Templates() {
+		names = append(names, t.Name())
+	}
+	slices.Sort(names)
+	return names
+}
+
+// sensitiveFlag matches the names of flags whose values must not be logged.
+var sensitiveFlag = regexp.MustCompile(`password|secret|token`)
+
+// flagValues returns the effective value of every flag, listing each value of
+// flags given several times. Flags defined with flag.Func don't report their
+// values, so the arguments are parsed again to record the values given.
+func flagValues(flags *flag.FlagSet, args []string) map[string]any {
+	recorded := map[string]*recordedFlag{}
+	recorder := flag.NewFlagSet(flags.Name(), flag.ContinueOnError)
+	recorder.SetOutput(io.Discard)
+	flags.VisitAll(func(f *flag.Flag) {
+		boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+		recorded[f.Name] = &recordedFlag{isBool: ok && boolFlag.IsBoolFlag()}
+		recorder.Var(recorded[f.Name], f.Name, f.Usage)
+	})
+	// The arguments have already been parsed successfully.
+	_ = recorder.Parse(args)
+
+	values := map[string]any{}
+	flags.VisitAll(func(f *flag.Flag) {
+		switch given := recorded[f.Name].values; {
+		case sensitiveFlag.MatchString(f.Name) && (len(given) > 0 || f.DefValue != ""):
+			values[f.Name] = "REDACTED"
+		case len(given) == 1:
+			values[f.Name] = given[0]
+		case len(given) > 1:
+			values[f.Name] = given
+		default:
+			values[f.Name] = f.DefValue
+		}
+	})
+	return values
+}
+
+// recordedFlag is a flag.Value that records the values it is set to.
+type recordedFlag struct {
+	isBool bool
+	values []string
+}
+
+func (f *recordedFlag) String() string   { return strings.Join(f.values, ",") }
+func (f *recordedFlag) IsBoolFlag() bool { return f.isBool }
+func (f *recordedFlag) Set(s string) error {
+	f.values = append(f.values, s)
+	return nil
 }
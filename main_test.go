@@ -0,0 +1,514 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// useTestTemplates gives the test its own copy of the page templates and
+// returns another for annotation templates, as templates can't be cloned
+// once executed and rendering executes srvTpl.
+func useTestTemplates(t *testing.T) *template.Template {
+	t.Helper()
+	orig := srvTpl
+	srvTpl = template.Must(orig.Clone())
+	t.Cleanup(func() { srvTpl = orig })
+	return template.Must(orig.Clone())
+}
+
+// renderIngresses runs a single reconcile of the ingresses and returns the
+// snapshot it rendered, along with the lines it logged.
+func renderIngresses(t *testing.T, opts reconcilerOptions, ingresses ...client.Object) (*renderSnapshot, []string) {
+	t.Helper()
+	tpl := useTestTemplates(t)
+	var logged []string
+	log := funcr.New(func(prefix, args string) {
+		logged = append(logged, args)
+	}, funcr.Options{})
+	var pagePtr atomic.Pointer[renderSnapshot]
+	kubeClient := fake.NewClientBuilder().WithObjects(ingresses...).Build()
+	if _, err := buildReconciler(log, kubeClient, &pagePtr, tpl, opts).Reconcile(context.Background(), reconcile.Request{}); err != nil {
+		t.Fatal(err)
+	}
+	return pagePtr.Load(), logged
+}
+
+func TestCheckPagePath(t *testing.T) {
+	for _, tc := range []struct {
+		path  string
+		valid bool
+	}{
+		{"/", true},
+		{"/links", true},
+		{"/team/links/", true},
+		{"/team/{rest...}", true},
+		{"links", false},
+		{"/a b", false},
+		{"/{x", false},
+		{"/{rest...}/more", false},
+	} {
+		if err := checkPagePath(tc.path); (err == nil) != tc.valid {
+			t.Errorf("checkPagePath(%q) = %v, want valid %v", tc.path, err, tc.valid)
+		}
+	}
+}
+
+func TestHostDetailPrefix(t *testing.T) {
+	for _, tc := range []struct {
+		pagePath string
+		request  string
+	}{
+		{"/", "/"},
+		{"/links", "/links"},
+		{"/team/links", "/team/links"},
+		{"/team/links/", "/team/links/"},
+		{"/team/{rest...}", "/team/a/b"},
+	} {
+		page, err := url.Parse("https://links.example.com" + tc.request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prefix := hostDetailPrefix(tc.pagePath)
+		link, err := page.Parse(prefix + "app.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if link.Path != "/host/app.example.com" {
+			t.Errorf("link %q from page %s served at %s resolves to %s, want /host/app.example.com", prefix, tc.request, tc.pagePath, link.Path)
+		}
+	}
+}
+
+func TestGroupTreeSortsWithinGroups(t *testing.T) {
+	hosts := []*hostValues{
+		{Host: "zzz.alpha.example.com", Group: "alpha", Order: 1},
+		{Host: "aaa.alpha.example.com", Group: "alpha", Order: 2, Primary: true},
+		{Host: "bbb.alpha.example.com", Group: "alpha", Order: 3, Pin: "top"},
+		{Host: "yyy.example.com", Order: 1},
+		{Host: "xxx.example.com", Order: 2, Primary: true},
+	}
+	slices.SortFunc(hosts, compareHosts)
+
+	var got [][]string
+	for _, group := range groupTree(hosts, nil, "", false) {
+		var names []string
+		for _, hv := range group.Hosts {
+			names = append(names, hv.Host)
+		}
+		got = append(got, names)
+	}
+	want := [][]string{
+		{"xxx.example.com", "yyy.example.com"},
+		{"bbb.alpha.example.com", "zzz.alpha.example.com", "aaa.alpha.example.com"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("group hosts differ (-want +got):\n%s", diff)
+	}
+}
+
+func TestHostURL(t *testing.T) {
+	for _, tc := range []struct {
+		host     string
+		port     string
+		insecure bool
+		want     string
+	}{
+		{"example.com", "", false, "https://example.com"},
+		{"example.com", "", true, "http://example.com"},
+		{"example.com", "8443", false, "https://example.com:8443"},
+		{"example.com", "8080", true, "http://example.com:8080"},
+		{"example.com", "443", false, "https://example.com"},
+		{"example.com", "443", true, "http://example.com:443"},
+		{"example.com", "80", true, "http://example.com"},
+		{"example.com", "80", false, "https://example.com:80"},
+		{"192.0.2.1", "", false, "https://192.0.2.1"},
+		{"192.0.2.1", "8080", true, "http://192.0.2.1:8080"},
+		{"2001:db8::1", "", false, "https://[2001:db8::1]"},
+		{"2001:db8::1", "8443", false, "https://[2001:db8::1]:8443"},
+		{"2001:db8::1", "8080", true, "http://[2001:db8::1]:8080"},
+		{"fe80::1%eth0", "", false, "https://[fe80::1%25eth0]"},
+		{"::ffff:192.0.2.1", "", false, "https://[::ffff:192.0.2.1]"},
+	} {
+		if got := hostURL(tc.host, tc.port, tc.insecure); got != tc.want {
+			t.Errorf("hostURL(%q, %q, %v) = %q, want %q", tc.host, tc.port, tc.insecure, got, tc.want)
+		}
+		if _, err := url.Parse(hostURL(tc.host, tc.port, tc.insecure)); err != nil {
+			t.Errorf("hostURL(%q, %q, %v) is not a valid URL: %v", tc.host, tc.port, tc.insecure, err)
+		}
+	}
+}
+
+func TestRenderAllCoalescesEvents(t *testing.T) {
+	tpl := useTestTemplates(t)
+	ctx := context.Background()
+
+	var ingresses []client.Object
+	for i := range 50 {
+		ingresses = append(ingresses, &netv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("app-%d", i), Namespace: "team"},
+			Spec:       netv1.IngressSpec{Rules: []netv1.IngressRule{{Host: fmt.Sprintf("app-%d.example.com", i)}}},
+		})
+	}
+	kubeClient := fake.NewClientBuilder().WithObjects(ingresses...).Build()
+
+	const resyncPeriod = 100 * time.Millisecond
+	var pagePtr atomic.Pointer[renderSnapshot]
+	reconciler := buildReconciler(logr.Discard(), kubeClient, &pagePtr, tpl, reconcilerOptions{resyncPeriod: resyncPeriod})
+
+	q := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer q.ShutDown()
+	renders := 0
+	// process handles the queued requests the way the controller does,
+	// requeueing each after the resync period.
+	process := func() {
+		for q.Len() > 0 {
+			req, _ := q.Get()
+			result, err := reconciler.Reconcile(ctx, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			renders++
+			if result.RequeueAfter != resyncPeriod {
+				t.Errorf("got requeue after %s, want the resync period %s", result.RequeueAfter, resyncPeriod)
+			}
+			q.Forget(req)
+			q.Done(req)
+			q.AddAfter(req, result.RequeueAfter)
+		}
+	}
+
+	// The informer's initial list creates every ingress.
+	for _, obj := range ingresses {
+		renderAll.Create(ctx, event.CreateEvent{Object: obj}, q)
+	}
+	process()
+	if renders != 1 {
+		t.Fatalf("got %d renders for the initial list, want 1", renders)
+	}
+	if got := len(pagePtr.Load().Hosts); got != len(ingresses) {
+		t.Fatalf("got %d hosts, want %d", got, len(ingresses))
+	}
+
+	// An informer resync updates every ingress, and the requeue for the
+	// resync period comes due while those updates are still queued.
+	for _, obj := range ingresses {
+		renderAll.Update(ctx, event.UpdateEvent{ObjectOld: obj, ObjectNew: obj}, q)
+	}
+	time.Sleep(2 * resyncPeriod)
+	process()
+	if renders != 2 {
+		t.Fatalf("got %d renders after the resync, want 2", renders)
+	}
+	if got := pagePtr.Load().Generation; got != 1 {
+		t.Errorf("got generation %d, want the unchanged render to keep generation 1", got)
+	}
+}
+
+func TestNotReadyEndpointsAreRetryable(t *testing.T) {
+	var pagePtr atomic.Pointer[renderSnapshot]
+	srv := buildServer(logr.Discard(), &pagePtr, serverOptions{pagePath: "/", sitemap: true})
+	for _, path := range []string{"/summary", "/links.md", "/sitemap.xml"} {
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("GET %s before the first render: got status %d, want %d", path, rec.Code, http.StatusServiceUnavailable)
+		}
+		if got := rec.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("GET %s before the first render: got content type %q, want a JSON error", path, got)
+		}
+	}
+}
+
+func TestServerShutdownEndsLongLivedRequests(t *testing.T) {
+	var pagePtr atomic.Pointer[renderSnapshot]
+	srv := buildServer(logr.Discard(), &pagePtr, serverOptions{pagePath: "/"})
+
+	// A handler streaming events until the client goes away, as SSE does,
+	// would otherwise hold up shutdown until the timeout.
+	started := make(chan struct{})
+	ended := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.Handle("/", srv.Handler)
+	mux.HandleFunc("GET /events", func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/event-stream")
+		rw.WriteHeader(http.StatusOK)
+		rw.(http.Flusher).Flush()
+		close(started)
+		<-req.Context().Done()
+		close(ended)
+	})
+	srv.Handler = mux
+
+	ts := httptest.NewUnstartedServer(nil)
+	ts.Config = srv
+	ts.Start()
+	defer ts.Close()
+
+	resp, err := ts.Client().Get(ts.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	<-started
+
+	const timeout = 5 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown failed after %s: %v", time.Since(start), err)
+	}
+	select {
+	case <-ended:
+	default:
+		t.Error("shutdown returned before the streaming handler ended")
+	}
+}
+
+func TestRestrictedHostsHiddenFromEveryFormat(t *testing.T) {
+	tpl := useTestTemplates(t)
+	ctx := context.Background()
+	kubeClient := fake.NewClientBuilder().Build()
+	feed := newLinkFeed("test")
+	var pagePtr atomic.Pointer[renderSnapshot]
+	reconciler := buildReconciler(logr.Discard(), kubeClient, &pagePtr, tpl, reconcilerOptions{feed: feed, splitByClass: true})
+	req := reconcile.Request{}
+	// The feed records the changes after the first render.
+	if _, err := reconciler.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	class := "nginx"
+	// Each team's ingress is in the team's namespace and restricted to the
+	// team's group, so that the name shows up in every format.
+	for _, team := range []string{"team-a", "team-b"} {
+		if err := kubeClient.Create(ctx, &netv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: team, Annotations: map[string]string{
+				allowedGroupsAnnotation: team,
+				compactAnnotation:       "true",
+			}},
+			Spec: netv1.IngressSpec{
+				IngressClassName: &class,
+				Rules:            []netv1.IngressRule{{Host: team + ".example.com"}},
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := reconciler.Reconcile(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := buildServer(logr.Discard(), &pagePtr, serverOptions{
+		pagePath:             "/",
+		requireForwardedUser: true,
+		feed:                 feed,
+		sitemap:              true,
+		embedFrameAncestors:  []string{"'self'"},
+	}).Handler
+	get := func(path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("X-Forwarded-User", "alice")
+		r.Header.Set("X-Forwarded-Groups", "team-a")
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, r)
+		return rw
+	}
+
+	for _, path := range []string{"/", "/summary", "/links.md", "/class/nginx", "/compact", "/feed.atom", "/sitemap.xml", "/embed", "/host/team-a.example.com"} {
+		rw := get(path)
+		if rw.Code != http.StatusOK {
+			t.Errorf("%s: got status %d, want 200", path, rw.Code)
+			continue
+		}
+		body := rw.Body.String()
+		if !strings.Contains(body, "team-a") {
+			t.Errorf("%s: missing the link of the user's group", path)
+		}
+		if strings.Contains(body, "team-b") {
+			t.Errorf("%s: shows the link of another group", path)
+		}
+	}
+	if rw := get("/host/team-b.example.com"); rw.Code != http.StatusNotFound {
+		t.Errorf("/host/team-b.example.com: got status %d, want 404", rw.Code)
+	}
+}
+
+func TestAddLinkFlags(t *testing.T) {
+	t.Cleanup(func() { setAnnotationPrefix(defaultAnnotationPrefix) })
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	opts := addLinkFlags(flags)
+	if err := flags.Parse([]string{
+		"--annotation-prefix", "links.example.com",
+		"--host-allow", "*.example.com",
+		"--global-skip-path-prefix", "/internal",
+		"--path-collision", "prefer-exact",
+		"--only-ready",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if annotationPrefix != "links.example.com/" {
+		t.Errorf("got annotation prefix %q, want links.example.com/", annotationPrefix)
+	}
+	if !slices.Equal(opts.hostAllow, []string{"*.example.com"}) || !slices.Equal(opts.skipPathPrefixes, []string{"/internal"}) || opts.pathCollision != "prefer-exact" || !opts.onlyReady {
+		t.Errorf("flags not applied to the options: %+v", opts)
+	}
+
+	defaults := addLinkFlags(flag.NewFlagSet("list", flag.ContinueOnError))
+	if !slices.Equal(defaults.skipPathPrefixes, defaultSkipPathPrefixes) || defaults.pathCollision != "merge" || defaults.maxTemplateSize != 64<<10 {
+		t.Errorf("got defaults %+v, want those of the controller", defaults)
+	}
+}
+
+func TestOversizedTemplatesSkipped(t *testing.T) {
+	prefix := netv1.PathTypePrefix
+	item := &netv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "oversized", Namespace: "team", Annotations: map[string]string{
+			hostTemplateAnnotation: "{{.Host}} " + strings.Repeat("x", 64),
+			pathTemplateAnnotation: "Admin",
+		}},
+		Spec: netv1.IngressSpec{Rules: []netv1.IngressRule{{
+			Host: "app.example.com",
+			IngressRuleValue: netv1.IngressRuleValue{HTTP: &netv1.HTTPIngressRuleValue{Paths: []netv1.HTTPIngressPath{
+				{Path: "/admin", PathType: &prefix},
+			}}},
+		}}},
+	}
+	oversized := oversizedTemplates.WithLabelValues("team", "oversized", "host")
+	before := testutil.ToFloat64(oversized)
+
+	snapshot, logged := renderIngresses(t, reconcilerOptions{maxTemplateSize: 64}, item)
+	if got := testutil.ToFloat64(oversized) - before; got != 1 {
+		t.Errorf("oversized host templates counter increased by %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(oversizedTemplates.WithLabelValues("team", "oversized", "path")); got != 0 {
+		t.Errorf("got %v oversized path templates, want the small one used", got)
+	}
+	if !slices.ContainsFunc(logged, func(line string) bool { return strings.Contains(line, "larger than --max-template-size") }) {
+		t.Errorf("oversized template not logged, got %q", logged)
+	}
+	if !strings.Contains(snapshot.Page, `href="https://app.example.com">app.example.com</a>`) {
+		t.Errorf("host not rendered with the default text:\n%s", snapshot.Page)
+	}
+	if strings.Contains(snapshot.Page, "xxxx") {
+		t.Error("oversized host template was rendered")
+	}
+	if !strings.Contains(snapshot.Page, ">Admin</a>") {
+		t.Errorf("path template within the limit was not rendered:\n%s", snapshot.Page)
+	}
+}
+
+func TestHostTemplateCloneFailureFallsBack(t *testing.T) {
+	tpl := useTestTemplates(t)
+	// Executing the templates prevents cloning them.
+	if err := tpl.Execute(io.Discard, newTemplateValues(nil, pageOptions{})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tpl.Clone(); err == nil {
+		t.Fatal("templates can still be cloned")
+	}
+
+	var logged []string
+	log := funcr.New(func(prefix, args string) {
+		logged = append(logged, args)
+	}, funcr.Options{})
+	kubeClient := fake.NewClientBuilder().WithObjects(
+		&netv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "named", Namespace: "team", Annotations: map[string]string{hostTemplateNameAnnotation: "hostlink"}},
+			Spec:       netv1.IngressSpec{Rules: []netv1.IngressRule{{Host: "named.example.com"}}},
+		},
+		&netv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "plain", Namespace: "team"},
+			Spec:       netv1.IngressSpec{Rules: []netv1.IngressRule{{Host: "plain.example.com"}}},
+		},
+	).Build()
+	templateFailures := templateErrors.WithLabelValues("team", "named", "host")
+	before := testutil.ToFloat64(templateFailures)
+
+	var pagePtr atomic.Pointer[renderSnapshot]
+	if _, err := buildReconciler(log, kubeClient, &pagePtr, tpl, reconcilerOptions{}).Reconcile(context.Background(), reconcile.Request{}); err != nil {
+		t.Fatalf("a clone failure failed the render: %v", err)
+	}
+	page := pagePtr.Load().Page
+	for _, host := range []string{"named.example.com", "plain.example.com"} {
+		if !strings.Contains(page, `href="https://`+host+`">`+host+`</a>`) {
+			t.Errorf("%s not rendered with the default text:\n%s", host, page)
+		}
+	}
+	if got := testutil.ToFloat64(templateFailures) - before; got != 1 {
+		t.Errorf("template errors counter increased by %v, want 1", got)
+	}
+	if !slices.ContainsFunc(logged, func(line string) bool {
+		return strings.Contains(line, "Failed to clone named host template, falling back") && strings.Contains(line, `"name"="named"`)
+	}) {
+		t.Errorf("clone failure not logged for the ingress, got %q", logged)
+	}
+}
+
+func TestPathCollision(t *testing.T) {
+	exact, prefix := netv1.PathTypeExact, netv1.PathTypePrefix
+	exactPath := netv1.HTTPIngressPath{Path: "/app", PathType: &exact}
+	prefixPath := netv1.HTTPIngressPath{Path: "/app", PathType: &prefix}
+	for _, tc := range []struct {
+		mode  string
+		paths []netv1.HTTPIngressPath
+		want  []string
+		links int
+	}{
+		{"merge", []netv1.HTTPIngressPath{exactPath, prefixPath}, []string{"/app Prefix: Prefix /app (Prefix)", "/app: Exact /app (Exact)"}, 2},
+		{"merge", []netv1.HTTPIngressPath{prefixPath, exactPath}, []string{"/app Exact: Exact /app (Exact)", "/app: Prefix /app (Prefix)"}, 2},
+		{"prefer-exact", []netv1.HTTPIngressPath{exactPath, prefixPath}, []string{"/app: Exact "}, 1},
+		{"prefer-exact", []netv1.HTTPIngressPath{prefixPath, exactPath}, []string{"/app: Exact "}, 1},
+		{"prefer-prefix", []netv1.HTTPIngressPath{exactPath, prefixPath}, []string{"/app: Prefix "}, 1},
+		{"prefer-prefix", []netv1.HTTPIngressPath{prefixPath, exactPath}, []string{"/app: Prefix "}, 1},
+	} {
+		t.Run(tc.mode+"/"+string(*tc.paths[0].PathType)+"First", func(t *testing.T) {
+			item := &netv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team"},
+				Spec: netv1.IngressSpec{Rules: []netv1.IngressRule{{
+					Host:             "app.example.com",
+					IngressRuleValue: netv1.IngressRuleValue{HTTP: &netv1.HTTPIngressRuleValue{Paths: tc.paths}},
+				}}},
+			}
+			snapshot, _ := renderIngresses(t, reconcilerOptions{pathCollision: tc.mode}, item)
+			host := snapshot.HostsByName["app.example.com"]
+			if host == nil {
+				t.Fatal("host not listed")
+			}
+			var got []string
+			for key, pv := range host.Paths {
+				got = append(got, fmt.Sprintf("%s: %s %s", key, pv.PathType, pv.Text))
+			}
+			slices.Sort(got)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("listed paths differ (-want +got):\n%s", diff)
+			}
+			if got := strings.Count(snapshot.Page, `href="https://app.example.com/app"`); got != tc.links {
+				t.Errorf("got %d links to the path, want %d", got, tc.links)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// writeMarkdown writes the links as a Markdown list, with a heading for each
+// named group. Nested groups get deeper headings.
+func writeMarkdown(w io.Writer, hosts []*hostValues) error {
+	values := newTemplateValues(hosts, pageOptions{})
+	for i, group := range flattenGroups(values.Groups) {
+		if group.Name != "" {
+			if i > 0 {
+				if _, err := fmt.Fprintln(w); err != nil {
+					return err
+				}
+			}
+			if _, err := fmt.Fprintf(w, "%s %s\n\n", strings.Repeat("#", min(group.Depth+2, 6)), escapeMarkdown(group.Name)); err != nil {
+				return err
+			}
+		}
+		for _, hv := range group.Hosts {
+			text := escapeMarkdown(linkText(hv.Text, cmp.Or(hv.DisplayHost, hv.Host)))
+			if !hv.Hostless {
+				text = fmt.Sprintf("[%s](%s%s)", text, markdownURL(hv.URL), provenanceTitle(hv))
+			}
+			if _, err := fmt.Fprintf(w, "- %s\n", text); err != nil {
+				return err
+			}
+			for _, pv := range sortedPaths(hv) {
+				if _, err := fmt.Fprintf(w, "  - [%s](%s)\n", escapeMarkdown(linkText(pv.Text, pv.Path)), markdownURL(pv.URL)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// linkText converts link text rendered from a template to plain text, falling
+// back to the given default as the page template does.
+func linkText(text template.HTML, fallback string) string {
+	if text == "" {
+		return fallback
+	}
+	var sb strings.Builder
+	inTag := false
+	for _, r := range string(text) {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+		case !inTag:
+			sb.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(sb.String())), " ")
+}
+
+// markdownEscaper escapes the characters with inline meaning in Markdown. Text
+// is only written within link text and after heading markers, so characters
+// that are only special at the start of a line do not need escaping.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`, `<`, `\<`, `>`, `\>`,
+)
+
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// provenanceTitle returns the link title recording the ingress the host was
+// declared by, if its provenance was recorded. UIDs and resource versions
+// don't contain quotes, so the title needs no escaping.
+func provenanceTitle(hv *hostValues) string {
+	if hv.UID == "" {
+		return ""
+	}
+	return fmt.Sprintf(` "uid %s, resourceVersion %s"`, hv.UID, hv.ResourceVersion)
+}
+
+// markdownURL escapes the characters that would end a Markdown link target.
+func markdownURL(u string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29", "<", "%3C", ">", "%3E").Replace(u)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// templateErrors counts failures to parse or execute annotation templates. Only
+// ingresses with broken templates get a series, which keeps cardinality low.
+var templateErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "ingress_links_template_errors_total",
+	Help: "Number of annotation templates that failed to parse or execute, by ingress and kind of template.",
+}, []string{"namespace", "name", "kind"})
+
+// oversizedTemplates counts the annotation templates skipped for exceeding
+// --max-template-size.
+var oversizedTemplates = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "ingress_links_oversized_templates_total",
+	Help: "Number of annotation templates skipped for exceeding the size limit, by ingress and kind of template.",
+}, []string{"namespace", "name", "kind"})
+
+// skippedPaths counts the ingress paths that are not listed on the page.
+var skippedPaths = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "ingress_links_skipped_paths_total",
+	Help: "Number of ingress paths not listed on the page when rendering, by reason.",
+}, []string{"reason"})
+
+// remoteListFailures counts the renders that could not list the ingresses of a
+// remote cluster, and used the ingresses it last listed instead.
+var remoteListFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "ingress_links_remote_cluster_list_failures_total",
+	Help: "Number of renders that failed to list the ingresses of a remote cluster, by cluster.",
+}, []string{"cluster"})
+
+// lastRender holds the time of the last successful render in Unix
+// nanoseconds, starting at process start so that a controller that never
+// renders still shows up as falling behind.
+var lastRender atomic.Int64
+
+func init() {
+	lastRender.Store(time.Now().UnixNano())
+
+	// The controller-runtime registry already carries the workqueue and
+	// controller metrics, and is served by the manager on the metrics port.
+	metrics.Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "ingress_links_seconds_since_last_render",
+		Help: "Seconds since the links page was last rendered successfully.",
+	}, func() float64 {
+		return time.Since(time.Unix(0, lastRender.Load())).Seconds()
+	}), templateErrors, oversizedTemplates, skippedPaths, remoteListFailures)
+}
+
+// recordRender marks a successful render for the metrics.
+func recordRender() {
+	lastRender.Store(time.Now().UnixNano())
+}
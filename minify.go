@@ -0,0 +1,79 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// rawTextElements are the elements whose content is kept as is when
+// minifying, as whitespace within them is significant or they aren't HTML.
+var rawTextElements = []string{"script", "style", "pre", "textarea"}
+
+// minifyHTML removes the whitespace between tags that the templates add for
+// readability, i.e. whitespace-only text containing a line break. Spaces
+// within a line, such as between inline links, and the content of raw text
+// elements are kept, so the page renders the same. It relies on the template
+// escaping < and > in text and attribute values.
+func minifyHTML(page string) string {
+	var sb strings.Builder
+	sb.Grow(len(page))
+	rest := page
+	for rest != "" {
+		start := strings.IndexByte(rest, '<')
+		if start < 0 {
+			start = len(rest)
+		}
+		if text := rest[:start]; strings.TrimSpace(text) != "" || !strings.Contains(text, "\n") {
+			sb.WriteString(text)
+		}
+		rest = rest[start:]
+		if rest == "" {
+			break
+		}
+
+		end := strings.IndexByte(rest, '>')
+		if end < 0 {
+			sb.WriteString(rest)
+			break
+		}
+		tag := rest[:end+1]
+		sb.WriteString(tag)
+		rest = rest[end+1:]
+
+		if name := rawTextElement(tag); name != "" {
+			closing := indexClosingTag(rest, name)
+			sb.WriteString(rest[:closing])
+			rest = rest[closing:]
+		}
+	}
+	return sb.String()
+}
+
+// indexClosingTag returns the index of the closing tag of the named element,
+// which may differ in case, or the length of s if there is none.
+func indexClosingTag(s, name string) int {
+	for offset := 0; ; {
+		i := strings.Index(s[offset:], "</")
+		if i < 0 {
+			return len(s)
+		}
+		start := offset + i
+		if end := start + 2 + len(name); end <= len(s) && strings.EqualFold(s[start+2:end], name) {
+			return start
+		}
+		offset = start + 2
+	}
+}
+
+// rawTextElement returns the name of the raw text element opened by the tag,
+// if any.
+func rawTextElement(tag string) string {
+	name := strings.ToLower(strings.TrimPrefix(tag, "<"))
+	if i := strings.IndexAny(name, " \t\n/>"); i >= 0 {
+		name = name[:i]
+	}
+	if slices.Contains(rawTextElements, name) {
+		return name
+	}
+	return ""
+}
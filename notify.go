@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+const (
+	notifyAttempts     = 3
+	notifyRetryBackoff = time.Second
+)
+
+// linksDiff is the body POSTed to the --notify-url when the page changes.
+type linksDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+func diffHosts(oldHosts, newHosts []*hostValues) linksDiff {
+	hostNames := func(hosts []*hostValues) []string {
+		var names []string
+		for _, hv := range hosts {
+			names = append(names, hv.Host)
+		}
+		return names
+	}
+	oldNames, newNames := hostNames(oldHosts), hostNames(newHosts)
+
+	diff := linksDiff{Added: []string{}, Removed: []string{}}
+	for _, name := range newNames {
+		if !slices.Contains(oldNames, name) {
+			diff.Added = append(diff.Added, name)
+		}
+	}
+	for _, name := range oldNames {
+		if !slices.Contains(newNames, name) {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	return diff
+}
+
+// notifier POSTs link changes to a webhook. Notifications are queued and sent
+// in order from a single goroutine so that a slow webhook does not hold up
+// reconciles.
+type notifier struct {
+	log    logr.Logger
+	url    string
+	client *http.Client
+	diffs  chan linksDiff
+}
+
+func newNotifier(log logr.Logger, client *http.Client, url string) *notifier {
+	return &notifier{
+		log:    log.WithName("notifier"),
+		url:    url,
+		client: client,
+		diffs:  make(chan linksDiff, 16),
+	}
+}
+
+// Notify queues a diff to be sent, dropping it if the queue is full.
+func (n *notifier) Notify(diff linksDiff) {
+	select {
+	case n.diffs <- diff:
+	default:
+		n.log.Info("Dropping link change notification, queue is full")
+	}
+}
+
+// Start sends queued notifications until the context is cancelled. It
+// implements manager.Runnable.
+func (n *notifier) Start(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case diff := <-n.diffs:
+			n.send(ctx, diff)
+		}
+	}
+}
+
+func (n *notifier) send(ctx context.Context, diff linksDiff) {
+	body, err := json.Marshal(diff)
+	if err != nil {
+		n.log.Error(err, "Failed to encode link change notification")
+		return
+	}
+
+	backoff := notifyRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err = n.post(ctx, body)
+		if err == nil {
+			return
+		}
+		if attempt == notifyAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	n.log.Error(err, "Failed to send link change notification", "url", n.url, "attempts", notifyAttempts)
+}
+
+func (n *notifier) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
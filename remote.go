@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	netv1 "k8s.io/api/networking/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sigs.k8s.io/yaml"
+)
+
+// remoteClusterConfig is an entry of the --remote-clusters file, describing an
+// additional API server to list ingresses from, such as a federation proxy.
+type remoteClusterConfig struct {
+	Name      string `json:"name"`
+	Server    string `json:"server"`
+	TokenFile string `json:"tokenFile"`
+	CAFile    string `json:"caFile"`
+}
+
+// remoteCluster is an additional API server whose ingresses are merged into
+// the page, read through its own cache.
+type remoteCluster struct {
+	name  string
+	cache cache.Cache
+}
+
+// listIngresses lists the ingresses in the remote cluster's cache. Until the
+// cache has synced, such as while the cluster is unreachable, this fails
+// rather than waiting for it to sync.
+func (r remoteCluster) listIngresses(ctx context.Context) ([]netv1.Ingress, error) {
+	informer, err := r.cache.GetInformer(ctx, &netv1.Ingress{}, cache.BlockUntilSynced(false))
+	if err != nil {
+		return nil, err
+	}
+	if !informer.HasSynced() {
+		return nil, errors.New("ingress cache has not synced")
+	}
+	is := &netv1.IngressList{}
+	if err := r.cache.List(ctx, is); err != nil {
+		return nil, err
+	}
+	return is.Items, nil
+}
+
+// unsyncedSource hides that a source can be waited on to sync, so that the
+// controller starts without waiting for it. Remote clusters' sources are
+// wrapped, as an unreachable remote cluster must not stop the controller.
+type unsyncedSource struct {
+	source.Source
+}
+
+func loadRemoteClusters(path string) ([]remoteClusterConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var configs []remoteClusterConfig
+	if err := yaml.UnmarshalStrict(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse remote clusters from %s: %w", path, err)
+	}
+	var names []string
+	for i, config := range configs {
+		switch {
+		case config.Name == "":
+			return nil, fmt.Errorf("remote cluster %d in %s has no name", i, path)
+		case config.Server == "":
+			return nil, fmt.Errorf("remote cluster %s in %s has no server", config.Name, path)
+		case slices.Contains(names, config.Name):
+			return nil, fmt.Errorf("remote cluster %s in %s is listed more than once", config.Name, path)
+		}
+		names = append(names, config.Name)
+	}
+	return configs, nil
+}
+
+// newRemoteCluster creates the cluster for a remote API server, authenticating
+// with the bearer token in its token file. The token file is re-read as it
+// changes, so that rotated tokens are picked up.
+func newRemoteCluster(config remoteClusterConfig) (cluster.Cluster, error) {
+	restConfig := &rest.Config{
+		Host:            config.Server,
+		BearerTokenFile: config.TokenFile,
+		TLSClientConfig: rest.TLSClientConfig{CAFile: config.CAFile},
+		UserAgent:       "ingress-links-controller/" + buildVersion(),
+	}
+	c, err := cluster.New(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client for remote cluster %s: %w", config.Name, err)
+	}
+	return c, nil
+}
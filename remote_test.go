@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// fakeRemoteCache lists the objects of a fake client, and otherwise behaves
+// like fake informers, whose sync state the test controls.
+type fakeRemoteCache struct {
+	*informertest.FakeInformers
+	reader client.Reader
+}
+
+func (c fakeRemoteCache) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return c.reader.List(ctx, list, opts...)
+}
+
+func TestUnavailableRemoteClusterKeepsLastIngresses(t *testing.T) {
+	tpl := useTestTemplates(t)
+	ctx := context.Background()
+	ingress := func(host string) client.Object {
+		return &netv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team", UID: types.UID(host)},
+			Spec:       netv1.IngressSpec{Rules: []netv1.IngressRule{{Host: host}}},
+		}
+	}
+	localClient := fake.NewClientBuilder().WithObjects(ingress("local.example.com")).Build()
+	informers := &informertest.FakeInformers{}
+	informer, err := informers.FakeInformerFor(ctx, &netv1.Ingress{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := remoteCluster{name: "east", cache: fakeRemoteCache{
+		FakeInformers: informers,
+		reader:        fake.NewClientBuilder().WithObjects(ingress("east.example.com")).Build(),
+	}}
+
+	var logged []string
+	log := funcr.New(func(prefix, args string) {
+		logged = append(logged, args)
+	}, funcr.Options{})
+	var pagePtr atomic.Pointer[renderSnapshot]
+	reconciler := buildReconciler(log, localClient, &pagePtr, tpl, reconcilerOptions{remoteClusters: []remoteCluster{remote}})
+	failures := remoteListFailures.WithLabelValues("east")
+	before := testutil.ToFloat64(failures)
+	render := func(step string, wantEast bool, wantFailures float64) {
+		t.Helper()
+		logged = nil
+		if _, err := reconciler.Reconcile(ctx, reconcile.Request{}); err != nil {
+			t.Fatalf("%s: %v", step, err)
+		}
+		page := pagePtr.Load().Page
+		if !strings.Contains(page, "local.example.com") {
+			t.Errorf("%s: local ingress missing", step)
+		}
+		if got := strings.Contains(page, "east.example.com"); got != wantEast {
+			t.Errorf("%s: got remote ingress shown %v, want %v", step, got, wantEast)
+		}
+		if got := testutil.ToFloat64(failures) - before; got != wantFailures {
+			t.Errorf("%s: got %v list failures, want %v", step, got, wantFailures)
+		}
+	}
+
+	informer.Synced = false
+	render("before the remote cache syncs", false, 1)
+	if len(logged) == 0 || !strings.Contains(strings.Join(logged, "\n"), `"cluster"="east"`) {
+		t.Errorf("list failure not logged, got %q", logged)
+	}
+	informer.Synced = true
+	render("once the remote cache syncs", true, 1)
+	informer.Synced = false
+	render("once the remote cluster is unavailable", true, 2)
+}
+
+func TestUnsyncedSourceIsNotWaitedFor(t *testing.T) {
+	var src source.Source = unsyncedSource{source.Kind[client.Object](&informertest.FakeInformers{}, &netv1.Ingress{}, renderAll)}
+	if _, ok := src.(source.SyncingSource); ok {
+		t.Error("the controller would wait for the remote cluster's source to sync")
+	}
+}
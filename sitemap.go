@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// writeSitemap writes the URLs of the sorted hosts and their listed paths as a
+// sitemap, with the render time as their last modification. Hosts without a
+// URL are left out.
+func writeSitemap(w io.Writer, hosts []*hostValues, renderedAt time.Time) error {
+	lastMod := renderedAt.UTC().Format(time.RFC3339)
+	var urlSet sitemapURLSet
+	for _, hv := range hosts {
+		if hv.URL == "" {
+			continue
+		}
+		urlSet.URLs = append(urlSet.URLs, sitemapURL{Loc: hv.URL, LastMod: lastMod})
+		for _, pv := range sortedPaths(hv) {
+			urlSet.URLs = append(urlSet.URLs, sitemapURL{Loc: pv.URL, LastMod: lastMod})
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	return enc.Encode(urlSet)
+}
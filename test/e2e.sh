@@ -95,6 +95,44 @@ expect_output \
   --header 'Host: links.localhost' \
   localhost:8123 <"${script_dir}/html/output.html"
 
+# Deleting an ingress removes its links from the page
+kubectl \
+  --context $context \
+  delete \
+  --namespace ingress-links \
+  ingress \
+  sort-key-ingress
+
+expect_output \
+  --expected - \
+  --attempts 10 \
+  --sleep 2 \
+  curl \
+  --no-progress-meter \
+  --max-time 2 \
+  --header 'Host: links.localhost' \
+  localhost:8123 <"${script_dir}/html/output-deleted.html"
+
+pod=$(
+  kubectl \
+    --context $context \
+    get \
+    pod \
+    --namespace ingress-links \
+    --selector=app.kubernetes.io/name=ingress-links-controller \
+    --field-selector=status.phase=Running \
+    --output=jsonpath='{.items[0].metadata.name}'
+)
+
+metrics=$(
+  kubectl \
+    --context $context \
+    get \
+    --raw "/api/v1/namespaces/ingress-links/pods/${pod}:8080/proxy/metrics"
+)
+grep --quiet '^ingress_links_seconds_since_last_render ' <<<"$metrics"
+grep --quiet '^workqueue_depth{.*name="ingress"' <<<"$metrics"
+
 log_success Success!
 
 ## Cleanup
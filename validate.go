@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+
+	netv1 "k8s.io/api/networking/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// validateTemplate implements the validate-template subcommand, which renders
+// host and path templates against an ingress read from a file, printing the
+// link text for each host and path. It returns the process exit code.
+func validateTemplate(args []string) int {
+	flags := flag.NewFlagSet("validate-template", flag.ContinueOnError)
+	ingressFile := flags.String("ingress", "", "YAML or JSON file containing the ingress to render the templates against")
+	hostTemplate := flags.String("host-template", "", "Host template to validate, defaulting to the ingress's host-template annotation")
+	pathTemplate := flags.String("path-template", "", "Path template to validate, defaulting to the ingress's path-template annotation")
+	loadTemplates := flags.String("load-templates", "", "Glob pattern for additional templates files to load")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *ingressFile == "" {
+		fmt.Fprintln(flags.Output(), "--ingress is required")
+		flags.Usage()
+		return 2
+	}
+
+	if *loadTemplates != "" {
+		if _, err := srvTpl.ParseGlob(*loadTemplates); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to parse templates from %s: %v\n", *loadTemplates, err)
+			return 1
+		}
+	}
+
+	data, err := os.ReadFile(*ingressFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var item netv1.Ingress
+	if err := yaml.Unmarshal(data, &item); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse ingress from %s: %v\n", *ingressFile, err)
+		return 1
+	}
+
+	if err := renderIngressTemplates(os.Stdout, &item, templateOrAnnotation(*hostTemplate, &item, hostTemplateAnnotation), templateOrAnnotation(*pathTemplate, &item, pathTemplateAnnotation)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// templateOrAnnotation returns the template if set, or else the named annotation
+// of the ingress.
+func templateOrAnnotation(text string, item *netv1.Ingress, annotation string) string {
+	if text != "" {
+		return text
+	}
+	return item.Annotations[annotation]
+}
+
+// renderIngressTemplates executes the host and path templates for each rule
+// and path of the ingress the way the reconciler does, writing one line per
+// link.
+func renderIngressTemplates(w io.Writer, item *netv1.Ingress, hostTemplate, pathTemplate string) error {
+	if hostTemplate == "" && pathTemplate == "" {
+		return errors.New("no host or path template to validate")
+	}
+
+	parse := func(text string) (*template.Template, error) {
+		if text == "" {
+			return nil, nil
+		}
+		return parseAnnotationTemplate(srvTpl, text)
+	}
+	hostTpl, err := parse(hostTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse host template: %w", err)
+	}
+	pathTpl, err := parse(pathTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse path template: %w", err)
+	}
+
+	for _, rule := range item.Spec.Rules {
+		if hostTpl != nil {
+			var sb strings.Builder
+			if err := hostTpl.Execute(&sb, hostTemplateValue{
+				Host:     rule.Host,
+				Ingress:  item,
+				Rule:     &rule,
+				TLSHosts: tlsHosts(item, rule.Host),
+			}); err != nil {
+				return fmt.Errorf("failed to execute host template for host %s: %w", rule.Host, err)
+			}
+			fmt.Fprintf(w, "%s: %s\n", rule.Host, sb.String())
+		}
+
+		if pathTpl == nil || rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			var sb strings.Builder
+			if err := pathTpl.Execute(&sb, pathTemplateValue{
+				Path:    &path,
+				Ingress: item,
+				Rule:    &rule,
+			}); err != nil {
+				return fmt.Errorf("failed to execute path template for %s%s: %w", rule.Host, path.Path, err)
+			}
+			fmt.Fprintf(w, "%s%s: %s\n", rule.Host, path.Path, sb.String())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRenderIngressTemplates(t *testing.T) {
+	prefix := netv1.PathTypePrefix
+	item := &netv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team"},
+		Spec: netv1.IngressSpec{Rules: []netv1.IngressRule{{
+			Host: "app.example.com",
+			IngressRuleValue: netv1.IngressRuleValue{HTTP: &netv1.HTTPIngressRuleValue{Paths: []netv1.HTTPIngressPath{
+				{Path: "/admin", PathType: &prefix},
+			}}},
+		}}},
+	}
+
+	for _, tc := range []struct {
+		name         string
+		hostTemplate string
+		pathTemplate string
+		want         string
+		wantErr      string
+	}{{
+		name:         "host and path",
+		hostTemplate: "{{.Host}} ({{.Ingress.Namespace}})",
+		pathTemplate: "{{.Path.Path}} of {{.Rule.Host}}",
+		want:         "app.example.com: app.example.com (team)\napp.example.com/admin: /admin of app.example.com\n",
+	}, {
+		name:         "path only",
+		pathTemplate: "Admin",
+		want:         "app.example.com/admin: Admin\n",
+	}, {
+		name:         "named template",
+		hostTemplate: `{{define "text"}}App{{end}}{{template "text"}}`,
+		want:         "app.example.com: App\n",
+	}, {
+		name:    "no templates",
+		wantErr: "no host or path template",
+	}, {
+		name:         "unparseable",
+		hostTemplate: "{{.Host",
+		wantErr:      "failed to parse host template",
+	}, {
+		name:         "failing",
+		pathTemplate: "{{.Path.Missing}}",
+		wantErr:      "failed to execute path template for app.example.com/admin",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var sb strings.Builder
+			err := renderIngressTemplates(&sb, item, tc.hostTemplate, tc.pathTemplate)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sb.String(); got != tc.want {
+				t.Errorf("got output %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseAnnotationTemplateLeavesPageTemplates(t *testing.T) {
+	tpl, err := parseAnnotationTemplate(srvTpl, `{{define "hostlink"}}replaced{{end}}{{template "hostlink"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	if err := tpl.Execute(&sb, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := sb.String(); got != "replaced" {
+		t.Errorf("got %q, want the annotation's hostlink", got)
+	}
+	if strings.Contains(srvTpl.Lookup("hostlink").Tree.Root.String(), "replaced") {
+		t.Error("annotation template replaced the page's hostlink template")
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	netv1 "k8s.io/api/networking/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// validateIngressPath is the path the validating webhook is served on.
+const validateIngressPath = "/validate-ingress"
+
+// ingressValidator rejects ingresses whose template annotations fail to parse,
+// parsing them the same way as the reconciler so that errors surface when the
+// ingress is applied rather than on the page.
+func ingressValidator(tpl *template.Template, decoder admission.Decoder) admission.HandlerFunc {
+	return func(ctx context.Context, req admission.Request) admission.Response {
+		var item netv1.Ingress
+		if err := decoder.Decode(req, &item); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+
+		for _, annotation := range []string{hostTemplateAnnotation, pathTemplateAnnotation} {
+			text := item.Annotations[annotation]
+			if text == "" {
+				continue
+			}
+			if _, err := parseAnnotationTemplate(tpl, text); err != nil {
+				return admission.Denied(fmt.Sprintf("annotation %s: %v", annotation, err))
+			}
+		}
+
+		// The reconciler falls back to the other templates for unknown
+		// template names, so they only warrant a warning.
+		if name := item.Annotations[hostTemplateNameAnnotation]; name != "" && tpl.Lookup(name) == nil {
+			return admission.Allowed("").WithWarnings(fmt.Sprintf("annotation %s: template %q not found", hostTemplateNameAnnotation, name))
+		}
+		return admission.Allowed("")
+	}
+}